@@ -0,0 +1,271 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"time"
+
+	"github.com/Ninad-Bhangui/gohuffman/huffman"
+)
+
+// Magic starts and ends every archive.
+var Magic = [4]byte{'G', 'H', 'A', 'R'}
+
+const FormatVersion = 1
+
+// footerSize is the table offset followed by Magic.
+const footerSize = 8 + len(Magic)
+
+var (
+	ErrFormat   = errors.New("archive: not a valid archive")
+	ErrNotFound = errors.New("archive: no such member")
+)
+
+// Entry describes one member. Every member is a separate huffman container,
+// so members can be read without decoding the rest of the archive.
+type Entry struct {
+	Path    string
+	Size    int64
+	Mode    fs.FileMode
+	ModTime time.Time
+	// Offset and Length locate the compressed member in the archive.
+	Offset int64
+	Length int64
+}
+
+// Writer creates an archive: Magic and the format version, the compressed
+// members, the file table and a footer holding the table's offset.
+type Writer struct {
+	w       io.Writer
+	offset  int64
+	entries []Entry
+	paths   map[string]bool
+}
+
+func NewWriter(w io.Writer) (*Writer, error) {
+	aw := &Writer{w: w, paths: map[string]bool{}}
+	err := aw.write(append(Magic[:], FormatVersion))
+	if err != nil {
+		return nil, err
+	}
+	return aw, nil
+}
+
+func (aw *Writer) write(p []byte) error {
+	n, err := aw.w.Write(p)
+	aw.offset += int64(n)
+	return err
+}
+
+// Add compresses r as the member path. Size, Mode and ModTime are taken from
+// info.
+func (aw *Writer) Add(path string, info fs.FileInfo, r io.Reader) error {
+	if !fs.ValidPath(path) || path == "." {
+		return fmt.Errorf("archive: invalid member path %q", path)
+	}
+	if aw.paths[path] {
+		return fmt.Errorf("archive: duplicate member %q", path)
+	}
+
+	entry := Entry{
+		Path:    path,
+		Mode:    info.Mode(),
+		ModTime: info.ModTime(),
+		Offset:  aw.offset,
+	}
+	cw := &countingWriter{w: aw.w}
+	zw := huffman.NewWriter(cw)
+	size, err := io.Copy(zw, r)
+	if err != nil {
+		return err
+	}
+	err = zw.Close()
+	if err != nil {
+		return err
+	}
+	entry.Size = size
+	entry.Length = cw.n
+	aw.offset += cw.n
+	aw.entries = append(aw.entries, entry)
+	aw.paths[path] = true
+	return nil
+}
+
+// AddFS adds every regular file in fsys, in lexical order.
+func (aw *Writer) AddFS(fsys fs.FS) error {
+	return aw.AddFSFunc(fsys, nil)
+}
+
+// AddFSFunc is like AddFS, but leaves out the files for which skip, if not
+// nil, returns true.
+func (aw *Writer) AddFSFunc(fsys fs.FS, skip func(path string, info fs.FileInfo) bool) error {
+	return fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if skip != nil && skip(path, info) {
+			return nil
+		}
+		f, err := fsys.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return aw.Add(path, info, f)
+	})
+}
+
+// Close writes the file table and the footer. It does not close the
+// underlying writer.
+func (aw *Writer) Close() error {
+	tableOffset := aw.offset
+	buf := binary.AppendUvarint(nil, uint64(len(aw.entries)))
+	for _, e := range aw.entries {
+		buf = binary.AppendUvarint(buf, uint64(len(e.Path)))
+		buf = append(buf, e.Path...)
+		buf = binary.AppendUvarint(buf, uint64(e.Size))
+		buf = binary.AppendUvarint(buf, uint64(e.Mode))
+		buf = binary.AppendVarint(buf, e.ModTime.UnixNano())
+		buf = binary.AppendUvarint(buf, uint64(e.Offset))
+		buf = binary.AppendUvarint(buf, uint64(e.Length))
+	}
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(tableOffset))
+	buf = append(buf, Magic[:]...)
+	return aw.write(buf)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// Reader gives access to the members of an archive.
+type Reader struct {
+	r       io.ReaderAt
+	Entries []Entry
+}
+
+// NewReader reads the file table of the archive in r, which is size bytes
+// long.
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	headerSize := int64(len(Magic) + 1)
+	if size < headerSize+int64(footerSize) {
+		return nil, ErrFormat
+	}
+	header := make([]byte, headerSize)
+	_, err := r.ReadAt(header, 0)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(header[:len(Magic)], Magic[:]) {
+		return nil, ErrFormat
+	}
+	if header[len(Magic)] != FormatVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrFormat, header[len(Magic)])
+	}
+
+	footer := make([]byte, footerSize)
+	_, err = r.ReadAt(footer, size-int64(footerSize))
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(footer[8:], Magic[:]) {
+		return nil, ErrFormat
+	}
+	tableOffset := int64(binary.LittleEndian.Uint64(footer))
+	tableEnd := size - int64(footerSize)
+	if tableOffset < headerSize || tableOffset > tableEnd {
+		return nil, ErrFormat
+	}
+	table := make([]byte, tableEnd-tableOffset)
+	_, err = r.ReadAt(table, tableOffset)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := parseTable(table, tableOffset)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{r: r, Entries: entries}, nil
+}
+
+func parseTable(table []byte, tableOffset int64) ([]Entry, error) {
+	br := bytes.NewReader(table)
+	var readErr error
+	readUvarint := func() int64 {
+		v, err := binary.ReadUvarint(br)
+		if err != nil || v > 1<<62 {
+			readErr = ErrFormat
+		}
+		return int64(v)
+	}
+
+	count := readUvarint()
+	if readErr != nil || count > int64(len(table)) {
+		return nil, ErrFormat
+	}
+	entries := make([]Entry, 0, count)
+	for i := int64(0); i < count; i++ {
+		pathLen := readUvarint()
+		if readErr != nil || pathLen > int64(br.Len()) {
+			return nil, ErrFormat
+		}
+		path := make([]byte, pathLen)
+		io.ReadFull(br, path)
+		e := Entry{Path: string(path), Size: readUvarint(), Mode: fs.FileMode(readUvarint())}
+		mtime, err := binary.ReadVarint(br)
+		if err != nil {
+			return nil, ErrFormat
+		}
+		e.ModTime = time.Unix(0, mtime)
+		e.Offset = readUvarint()
+		e.Length = readUvarint()
+		if readErr != nil || !fs.ValidPath(e.Path) || e.Path == "." ||
+			e.Offset > tableOffset || e.Length > tableOffset-e.Offset {
+			return nil, ErrFormat
+		}
+		entries = append(entries, e)
+	}
+	if br.Len() != 0 {
+		return nil, ErrFormat
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries, nil
+}
+
+// Entry returns the member called path.
+func (ar *Reader) Entry(path string) (Entry, error) {
+	i := sort.Search(len(ar.Entries), func(i int) bool { return ar.Entries[i].Path >= path })
+	if i == len(ar.Entries) || ar.Entries[i].Path != path {
+		return Entry{}, fmt.Errorf("%w: %s", ErrNotFound, path)
+	}
+	return ar.Entries[i], nil
+}
+
+// Open returns a reader for the decompressed contents of e.
+func (ar *Reader) Open(e Entry) (io.ReadCloser, error) {
+	zr, err := huffman.NewReader(io.NewSectionReader(ar.r, e.Offset, e.Length))
+	if err != nil {
+		return nil, err
+	}
+	return zr, nil
+}
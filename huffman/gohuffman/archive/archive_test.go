@@ -0,0 +1,96 @@
+package archive
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var modTime = time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"readme.txt":         {Data: []byte("hello archive"), Mode: 0o644, ModTime: modTime},
+		"src/main.go":        {Data: bytes.Repeat([]byte("package main\n"), 100), Mode: 0o600, ModTime: modTime},
+		"src/empty":          {Data: nil, Mode: 0o644, ModTime: modTime},
+		"bin/tool":           {Data: []byte{0x7f, 'E', 'L', 'F', 0x00, 0xff, 0xfe}, Mode: 0o755, ModTime: modTime},
+		"src/nested/ünï.txt": {Data: []byte("ünïcödé"), Mode: 0o644, ModTime: modTime},
+	}
+}
+
+func writeArchive(t *testing.T, fsys fs.FS) []byte {
+	var buf bytes.Buffer
+	aw, err := NewWriter(&buf)
+	assert.NoError(t, err)
+	assert.NoError(t, aw.AddFS(fsys))
+	assert.NoError(t, aw.Close())
+	return buf.Bytes()
+}
+
+func TestArchiveRoundtrip(t *testing.T) {
+	fsys := testFS()
+	data := writeArchive(t, fsys)
+
+	ar, err := NewReader(bytes.NewReader(data), int64(len(data)))
+	assert.NoError(t, err)
+	assert.Len(t, ar.Entries, len(fsys))
+
+	for _, e := range ar.Entries {
+		file := fsys[e.Path]
+		assert.Equal(t, int64(len(file.Data)), e.Size, e.Path)
+		assert.Equal(t, file.Mode, e.Mode, e.Path)
+		assert.True(t, file.ModTime.Equal(e.ModTime), e.Path)
+
+		rc, err := ar.Open(e)
+		assert.NoError(t, err)
+		contents, err := io.ReadAll(rc)
+		assert.NoError(t, err)
+		assert.Equal(t, string(file.Data), string(contents), e.Path)
+		assert.NoError(t, rc.Close())
+	}
+}
+
+func TestArchiveEntryLookup(t *testing.T) {
+	data := writeArchive(t, testFS())
+	ar, err := NewReader(bytes.NewReader(data), int64(len(data)))
+	assert.NoError(t, err)
+
+	e, err := ar.Entry("src/main.go")
+	assert.NoError(t, err)
+	assert.Equal(t, "src/main.go", e.Path)
+
+	_, err = ar.Entry("src/missing.go")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestWriterRejectsBadPaths(t *testing.T) {
+	aw, err := NewWriter(io.Discard)
+	assert.NoError(t, err)
+	info, err := fs.Stat(testFS(), "readme.txt")
+	assert.NoError(t, err)
+
+	for _, path := range []string{"../etc/passwd", "/abs", "a//b", "."} {
+		assert.Error(t, aw.Add(path, info, bytes.NewReader(nil)), path)
+	}
+	assert.NoError(t, aw.Add("a", info, bytes.NewReader(nil)))
+	assert.Error(t, aw.Add("a", info, bytes.NewReader(nil)))
+}
+
+func TestNewReaderRejectsForeignData(t *testing.T) {
+	data := writeArchive(t, testFS())
+	inputs := map[string][]byte{
+		"empty":      {},
+		"text":       []byte("this is not an archive, just some text"),
+		"no footer":  data[:len(data)-1],
+		"bad offset": append(bytes.Clone(data[:len(data)-footerSize]), 0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0, 'G', 'H', 'A', 'R'),
+	}
+	for name, input := range inputs {
+		_, err := NewReader(bytes.NewReader(input), int64(len(input)))
+		assert.ErrorIs(t, err, ErrFormat, name)
+	}
+}
@@ -2,35 +2,144 @@ package bitreader
 
 import "io"
 
+// bufferSize is how many bytes are read from the underlying reader at once.
+const bufferSize = 4096
+
+// BitOrder is the order bits are packed into a byte in.
+type BitOrder int
+
+const (
+	// MSBFirst reads bytes from the most significant bit down.
+	MSBFirst BitOrder = iota
+	// LSBFirst reads bytes from the least significant bit up, as DEFLATE
+	// does.
+	LSBFirst
+)
+
+// Option configures a BitReader.
+type Option func(*BitReader)
+
+// WithBitOrder sets the order bits are read in. The default is MSBFirst.
+func WithBitOrder(order BitOrder) Option {
+	return func(br *BitReader) {
+		br.order = order
+	}
+}
+
+// BitReader reads bits from bytes. It reads ahead, so once done with the
+// bits the underlying reader is past them.
 type BitReader struct {
 	reader io.Reader
-	buffer byte
-	count  int
+	order  BitOrder
+	buf    []byte
+	pos    int
+	// acc holds count unread bits. The next one is the most significant of
+	// them with MSBFirst and the least significant with LSBFirst.
+	acc   uint64
+	count int
+	err   error
 }
 
-func NewBitReader(reader io.Reader) BitReader {
-	return BitReader{
+func NewBitReader(reader io.Reader, opts ...Option) BitReader {
+	br := BitReader{
 		reader: reader,
-		buffer: 0,
-		count:  0,
+		buf:    make([]byte, 0, bufferSize),
+	}
+	for _, opt := range opts {
+		opt(&br)
 	}
+	return br
 }
 
-func (br *BitReader) ReadBit() (bool, error) {
-	if br.count == 0 {
-		buf := []byte{0}
-		n, err := br.reader.Read(buf)
+// fill loads whole bytes into acc until it holds more than 56 bits or the
+// input runs out.
+func (br *BitReader) fill() {
+	for br.count <= 56 {
+		if br.pos == len(br.buf) {
+			if br.err != nil {
+				return
+			}
+			n, err := br.reader.Read(br.buf[:cap(br.buf)])
+			br.buf, br.pos = br.buf[:n], 0
+			if n == 0 && err == nil {
+				err = io.EOF
+			}
+			br.err = err
+			continue
+		}
+		if br.order == LSBFirst {
+			br.acc |= uint64(br.buf[br.pos]) << br.count
+		} else {
+			br.acc = br.acc<<8 | uint64(br.buf[br.pos])
+		}
+		br.pos++
+		br.count += 8
+	}
+}
+
+// ReadBits reads n bits, n being at most 64, and returns them as an
+// integer. With MSBFirst the first bit read is the most significant, with
+// LSBFirst the least significant, matching BitWriter.WriteBits. It returns
+// io.EOF if no bits are left and io.ErrUnexpectedEOF if fewer than n are.
+func (br *BitReader) ReadBits(n int) (uint64, error) {
+	if n > 56 {
+		firstLen := n - 32
+		if br.order == LSBFirst {
+			firstLen = 32
+		}
+		first, err := br.ReadBits(firstLen)
 		if err != nil {
-			return false, err
+			return 0, err
 		}
-		if n == 0 {
-			return false, io.EOF
+		second, err := br.ReadBits(n - firstLen)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
 		}
-		br.buffer = buf[0]
-		br.count = 8
+		if br.order == LSBFirst {
+			return second<<firstLen | first, err
+		}
+		return first<<(n-firstLen) | second, err
+	}
+	if br.count < n {
+		br.fill()
+		if br.count < n {
+			return 0, br.shortErr()
+		}
+	}
+	br.count -= n
+	if br.order == LSBFirst {
+		value := br.acc & (1<<n - 1)
+		br.acc >>= n
+		return value, nil
+	}
+	value := br.acc >> br.count
+	br.acc &= 1<<br.count - 1
+	return value, nil
+}
+
+func (br *BitReader) shortErr() error {
+	if br.err != io.EOF {
+		return br.err
+	}
+	if br.count > 0 {
+		return io.ErrUnexpectedEOF
+	}
+	return io.EOF
+}
+
+func (br *BitReader) ReadBit() (bool, error) {
+	bit, err := br.ReadBits(1)
+	return bit == 1, err
+}
+
+// Align drops the bits left in the current byte, so the next read starts at
+// a byte boundary.
+func (br *BitReader) Align() {
+	drop := br.count % 8
+	br.count -= drop
+	if br.order == LSBFirst {
+		br.acc >>= drop
+	} else {
+		br.acc &= 1<<br.count - 1
 	}
-	bitPos := br.count - 1
-	bit := (br.buffer >> bitPos) & 1
-	br.count--
-	return bit == 1, nil
 }
@@ -0,0 +1,233 @@
+package bitreader
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/Ninad-Bhangui/gohuffman/bitwriter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadBit(t *testing.T) {
+	br := NewBitReader(bytes.NewReader([]byte{0b10100000}))
+
+	var bits []bool
+	for i := 0; i < 8; i++ {
+		bit, err := br.ReadBit()
+		assert.NoError(t, err)
+		bits = append(bits, bit)
+	}
+	assert.Equal(t, []bool{true, false, true, false, false, false, false, false}, bits)
+
+	_, err := br.ReadBit()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReadBits(t *testing.T) {
+	data := []byte{0b101_11111, 0xff, 0b111_00000, 0, 0b000_11111, 0xff, 0b111_00000, 0, 0b000_1_0000}
+	br := NewBitReader(bytes.NewReader(data))
+
+	value, err := br.ReadBits(3)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0b101), value)
+	value, err = br.ReadBits(64)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0xffff_0000_ffff_0000), value)
+	value, err = br.ReadBits(0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), value)
+	value, err = br.ReadBits(1)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), value)
+
+	_, err = br.ReadBits(5)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	value, err = br.ReadBits(4)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), value)
+	_, err = br.ReadBits(1)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReadBitsRoundtrip(t *testing.T) {
+	var buf bytes.Buffer
+	bw := bitwriter.NewBitWriter(&buf)
+	for i := 0; i < 10000; i++ {
+		assert.NoError(t, bw.WriteBits(uint64(i*2654435761), i%65))
+	}
+	assert.NoError(t, bw.Flush())
+
+	// One byte at a time, to read across many refills.
+	br := NewBitReader(iotest.OneByteReader(&buf))
+	for i := 0; i < 10000; i++ {
+		n := i % 65
+		value, err := br.ReadBits(n)
+		assert.NoError(t, err)
+		want := uint64(i * 2654435761)
+		if n < 64 {
+			want &= 1<<n - 1
+		}
+		assert.Equal(t, want, value)
+	}
+}
+
+func TestAlign(t *testing.T) {
+	br := NewBitReader(bytes.NewReader([]byte{0b10111111, 0b01000000}))
+	bit, err := br.ReadBit()
+	assert.NoError(t, err)
+	assert.True(t, bit)
+
+	br.Align()
+	value, err := br.ReadBits(2)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0b01), value)
+}
+
+func TestReadBitsReportsReadErrors(t *testing.T) {
+	errBoom := errors.New("boom")
+	br := NewBitReader(io.MultiReader(bytes.NewReader([]byte{0xff}), iotest.ErrReader(errBoom)))
+	value, err := br.ReadBits(8)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0xff), value)
+	_, err = br.ReadBits(1)
+	assert.Equal(t, errBoom, err)
+}
+
+func BenchmarkReadBit(b *testing.B) {
+	data := bytes.Repeat([]byte{0b01101110}, 1<<10)
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		br := NewBitReader(bytes.NewReader(data))
+		for j := 0; j < len(data)*8; j++ {
+			br.ReadBit()
+		}
+	}
+}
+
+func BenchmarkReadBits(b *testing.B) {
+	data := bytes.Repeat([]byte{0b01101110}, 1<<10)
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		br := NewBitReader(bytes.NewReader(data))
+		for j := 0; j < len(data); j++ {
+			br.ReadBits(5)
+			br.ReadBits(3)
+		}
+	}
+}
+
+func TestReadBitsLSBFirst(t *testing.T) {
+	br := NewBitReader(bytes.NewReader([]byte{0x6d, 0x5e, 0x05}), WithBitOrder(LSBFirst))
+
+	bit, err := br.ReadBit()
+	assert.NoError(t, err)
+	assert.True(t, bit)
+	value, err := br.ReadBits(2)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0b10), value)
+	value, err = br.ReadBits(16)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0xabcd), value)
+
+	br.Align()
+	_, err = br.ReadBit()
+	assert.Equal(t, io.EOF, err)
+}
+
+// TestReadBitsLSBFirstDeflate reads the stored block compress/flate writes
+// without compression: the final flag and block type, then the length and
+// its complement at the next byte boundary.
+func TestReadBitsLSBFirstDeflate(t *testing.T) {
+	var buf bytes.Buffer
+	fw, err := flate.NewWriter(&buf, flate.NoCompression)
+	assert.NoError(t, err)
+	_, err = fw.Write([]byte("stored"))
+	assert.NoError(t, err)
+	assert.NoError(t, fw.Close())
+
+	br := NewBitReader(&buf, WithBitOrder(LSBFirst))
+	_, err = br.ReadBits(1)
+	assert.NoError(t, err)
+	blockType, err := br.ReadBits(2)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), blockType)
+	br.Align()
+	length, err := br.ReadBits(16)
+	assert.NoError(t, err)
+	complement, err := br.ReadBits(16)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0xffff), length^complement)
+	assert.Equal(t, uint64(len("stored")), length)
+	var data []byte
+	for i := uint64(0); i < length; i++ {
+		c, err := br.ReadBits(8)
+		assert.NoError(t, err)
+		data = append(data, byte(c))
+	}
+	assert.Equal(t, "stored", string(data))
+}
+
+func TestReadBitsRoundtripBothOrders(t *testing.T) {
+	for _, order := range []struct {
+		name   string
+		writer bitwriter.BitOrder
+		reader BitOrder
+	}{
+		{"msb first", bitwriter.MSBFirst, MSBFirst},
+		{"lsb first", bitwriter.LSBFirst, LSBFirst},
+	} {
+		t.Run(order.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			bw := bitwriter.NewBitWriter(&buf, bitwriter.WithBitOrder(order.writer))
+			for i := 0; i < 10000; i++ {
+				assert.NoError(t, bw.WriteBits(uint64(i*2654435761), i%65))
+			}
+			assert.NoError(t, bw.Flush())
+
+			br := NewBitReader(iotest.OneByteReader(&buf), WithBitOrder(order.reader))
+			for i := 0; i < 10000; i++ {
+				n := i % 65
+				value, err := br.ReadBits(n)
+				assert.NoError(t, err)
+				want := uint64(i * 2654435761)
+				if n < 64 {
+					want &= 1<<n - 1
+				}
+				assert.Equal(t, want, value)
+			}
+		})
+	}
+}
+
+func TestReadBitsAcrossOrders(t *testing.T) {
+	// Bits written in one order come back within each byte mirrored when
+	// read in the other.
+	var buf bytes.Buffer
+	bw := bitwriter.NewBitWriter(&buf)
+	assert.NoError(t, bw.WriteBits(0b1100_0001_0110_1010, 16))
+	assert.NoError(t, bw.Flush())
+
+	br := NewBitReader(bytes.NewReader(buf.Bytes()), WithBitOrder(LSBFirst))
+	var got []uint64
+	for i := 0; i < 2; i++ {
+		b, err := br.ReadBits(8)
+		assert.NoError(t, err)
+		got = append(got, b)
+	}
+	// With LSBFirst the first bit read is the least significant, so reading
+	// whole bytes gives the bytes as written.
+	assert.Equal(t, []uint64{0b1100_0001, 0b0110_1010}, got)
+
+	br = NewBitReader(bytes.NewReader(buf.Bytes()), WithBitOrder(LSBFirst))
+	var bits []uint64
+	for i := 0; i < 8; i++ {
+		bit, err := br.ReadBits(1)
+		assert.NoError(t, err)
+		bits = append(bits, bit)
+	}
+	assert.Equal(t, []uint64{1, 0, 0, 0, 0, 0, 1, 1}, bits)
+}
@@ -2,38 +2,98 @@ package bitwriter
 
 import "io"
 
+// bufferSize is how many whole bytes are collected before they are written.
+const bufferSize = 4096
+
+// BitOrder is the order bits are packed into a byte in.
+type BitOrder int
+
+const (
+	// MSBFirst fills bytes from the most significant bit down.
+	MSBFirst BitOrder = iota
+	// LSBFirst fills bytes from the least significant bit up, as DEFLATE
+	// does.
+	LSBFirst
+)
+
+// Option configures a BitWriter.
+type Option func(*BitWriter)
+
+// WithBitOrder sets the order bits are packed in. The default is MSBFirst.
+func WithBitOrder(order BitOrder) Option {
+	return func(bw *BitWriter) {
+		bw.order = order
+	}
+}
+
+// BitWriter packs bits into bytes. Bits gather in a 64-bit accumulator and
+// whole bytes in a buffer, so nothing reaches the underlying writer before
+// the buffer fills up or Flush is called.
 type BitWriter struct {
 	writer io.Writer
-	buffer byte
-	count  int
+	order  BitOrder
+	buf    []byte
+	// acc holds the count bits that do not make up a whole byte yet. The
+	// oldest bit is the most significant of them with MSBFirst and the least
+	// significant with LSBFirst.
+	acc   uint64
+	count int
 }
 
-func NewBitWriter(writer io.Writer) BitWriter {
-	return BitWriter{
+func NewBitWriter(writer io.Writer, opts ...Option) BitWriter {
+	bw := BitWriter{
 		writer: writer,
-		buffer: 0,
-		count:  0,
+		buf:    make([]byte, 0, bufferSize),
+	}
+	for _, opt := range opts {
+		opt(&bw)
 	}
+	return bw
 }
 
-func (bw *BitWriter) WriteBitsFromString(bits string) error {
-	for _, b := range bits {
-		err := bw.WriteBit(b == '1')
+// WriteBits writes the low n bits of value, n being at most 64. With
+// MSBFirst the most significant of them is written first, with LSBFirst the
+// least significant, so a value keeps its meaning in both orders.
+func (bw *BitWriter) WriteBits(value uint64, n int) error {
+	if n > 56 {
+		// Keep acc from overflowing, since it can hold 7 bits already.
+		first, firstLen := value>>32, n-32
+		value, n = value&(1<<32-1), 32
+		if bw.order == LSBFirst {
+			first, firstLen, value, n = value, n, first, firstLen
+		}
+		err := bw.WriteBits(first, firstLen)
 		if err != nil {
 			return err
 		}
 	}
+	value &= 1<<n - 1
+	if bw.order == LSBFirst {
+		bw.acc |= value << bw.count
+		bw.count += n
+		for bw.count >= 8 {
+			bw.buf = append(bw.buf, byte(bw.acc))
+			bw.acc >>= 8
+			bw.count -= 8
+		}
+	} else {
+		bw.acc = bw.acc<<n | value
+		bw.count += n
+		for bw.count >= 8 {
+			bw.count -= 8
+			bw.buf = append(bw.buf, byte(bw.acc>>bw.count))
+		}
+		bw.acc &= 1<<bw.count - 1
+	}
+	if len(bw.buf) >= bufferSize {
+		return bw.flushBuffer()
+	}
 	return nil
 }
 
-func (bw *BitWriter) WriteBit(bit bool) error {
-	if bit {
-		bw.buffer |= 1 << (7 - bw.count)
-	}
-	bw.count++
-
-	if bw.count == 8 {
-		err := bw.flushByte()
+func (bw *BitWriter) WriteBitsFromString(bits string) error {
+	for _, b := range bits {
+		err := bw.WriteBit(b == '1')
 		if err != nil {
 			return err
 		}
@@ -41,19 +101,32 @@ func (bw *BitWriter) WriteBit(bit bool) error {
 	return nil
 }
 
-func (bw *BitWriter) flushByte() error {
-	_, err := bw.writer.Write([]byte{bw.buffer})
-	if err != nil {
-		return err
+func (bw *BitWriter) WriteBit(bit bool) error {
+	if bit {
+		return bw.WriteBits(1, 1)
 	}
-	bw.buffer = 0
-	bw.count = 0
+	return bw.WriteBits(0, 1)
+}
+
+func (bw *BitWriter) flushBuffer() error {
+	_, err := bw.writer.Write(bw.buf)
+	bw.buf = bw.buf[:0]
 	return err
 }
 
+// Flush writes everything written so far, padding the last byte with zeros.
 func (bw *BitWriter) Flush() error {
-	if bw.count == 0 {
+	if bw.count > 0 {
+		last := byte(bw.acc)
+		if bw.order == MSBFirst {
+			last = byte(bw.acc << (8 - bw.count))
+		}
+		bw.buf = append(bw.buf, last)
+		bw.acc = 0
+		bw.count = 0
+	}
+	if len(bw.buf) == 0 {
 		return nil
 	}
-	return bw.flushByte()
+	return bw.flushBuffer()
 }
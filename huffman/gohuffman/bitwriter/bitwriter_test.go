@@ -2,6 +2,9 @@ package bitwriter
 
 import (
 	"bytes"
+	"compress/flate"
+	"io"
+	"math/bits"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -11,8 +14,9 @@ func TestNewBitWriter(t *testing.T) {
 	var buf bytes.Buffer
 	bw := NewBitWriter(&buf)
 	
-	assert.Equal(t, byte(0), bw.buffer)
+	assert.Equal(t, uint64(0), bw.acc)
 	assert.Equal(t, 0, bw.count)
+	assert.Empty(t, bw.buf)
 	assert.Equal(t, &buf, bw.writer)
 }
 
@@ -23,19 +27,19 @@ func TestWriteBit(t *testing.T) {
 	// Write a single bit (1)
 	err := bw.WriteBit(true)
 	assert.NoError(t, err)
-	assert.Equal(t, byte(128), bw.buffer) // 10000000
+	assert.Equal(t, uint64(1), bw.acc) // 1
 	assert.Equal(t, 1, bw.count)
 	
 	// Write another bit (0)
 	err = bw.WriteBit(false)
 	assert.NoError(t, err)
-	assert.Equal(t, byte(128), bw.buffer) // 10000000
+	assert.Equal(t, uint64(2), bw.acc) // 10
 	assert.Equal(t, 2, bw.count)
 	
 	// Write another bit (1)
 	err = bw.WriteBit(true)
 	assert.NoError(t, err)
-	assert.Equal(t, byte(160), bw.buffer) // 10100000
+	assert.Equal(t, uint64(5), bw.acc) // 101
 	assert.Equal(t, 3, bw.count)
 }
 
@@ -50,13 +54,15 @@ func TestWriteBitFlushOnFullByte(t *testing.T) {
 		assert.NoError(t, err)
 	}
 	
-	// Buffer should be flushed and reset
-	assert.Equal(t, byte(0), bw.buffer)
+	// The whole byte moves from the accumulator to the buffer
+	assert.Equal(t, uint64(0), bw.acc)
 	assert.Equal(t, 0, bw.count)
+	assert.Equal(t, []byte{170}, bw.buf) // 10101010 = 170
 	
-	// Check that byte was written to buffer
-	assert.Equal(t, 1, buf.Len())
-	assert.Equal(t, byte(170), buf.Bytes()[0]) // 10101010 = 170
+	// and is written on Flush
+	assert.Equal(t, 0, buf.Len())
+	assert.NoError(t, bw.Flush())
+	assert.Equal(t, []byte{170}, buf.Bytes())
 }
 
 func TestWriteBitsFromString(t *testing.T) {
@@ -67,11 +73,10 @@ func TestWriteBitsFromString(t *testing.T) {
 	err := bw.WriteBitsFromString("10101010")
 	assert.NoError(t, err)
 	
-	// Should have flushed automatically
-	assert.Equal(t, byte(0), bw.buffer)
+	// Should have made up a whole byte
+	assert.Equal(t, uint64(0), bw.acc)
 	assert.Equal(t, 0, bw.count)
-	assert.Equal(t, 1, buf.Len())
-	assert.Equal(t, byte(170), buf.Bytes()[0]) // 10101010 = 170
+	assert.Equal(t, []byte{170}, bw.buf) // 10101010 = 170
 }
 
 func TestWriteBitsFromStringPartial(t *testing.T) {
@@ -82,9 +87,10 @@ func TestWriteBitsFromStringPartial(t *testing.T) {
 	err := bw.WriteBitsFromString("101")
 	assert.NoError(t, err)
 	
-	// Should not have flushed yet
-	assert.Equal(t, byte(160), bw.buffer) // 10100000
+	// Should not have made up a byte yet
+	assert.Equal(t, uint64(5), bw.acc) // 101
 	assert.Equal(t, 3, bw.count)
+	assert.Empty(t, bw.buf)
 	assert.Equal(t, 0, buf.Len())
 }
 
@@ -101,8 +107,9 @@ func TestFlush(t *testing.T) {
 	assert.NoError(t, err)
 	
 	// Buffer should be reset
-	assert.Equal(t, byte(0), bw.buffer)
+	assert.Equal(t, uint64(0), bw.acc)
 	assert.Equal(t, 0, bw.count)
+	assert.Empty(t, bw.buf)
 	
 	// Byte should be written with padding zeros
 	assert.Equal(t, 1, buf.Len())
@@ -119,7 +126,7 @@ func TestFlushEmptyBuffer(t *testing.T) {
 	
 	// Nothing should be written
 	assert.Equal(t, 0, buf.Len())
-	assert.Equal(t, byte(0), bw.buffer)
+	assert.Equal(t, uint64(0), bw.acc)
 	assert.Equal(t, 0, bw.count)
 }
 
@@ -130,6 +137,8 @@ func TestMultipleBytes(t *testing.T) {
 	// Write 16 bits: "1010101011001100"
 	err := bw.WriteBitsFromString("1010101011001100")
 	assert.NoError(t, err)
+	err = bw.Flush()
+	assert.NoError(t, err)
 	
 	// Should have written 2 bytes
 	assert.Equal(t, 2, buf.Len())
@@ -137,8 +146,9 @@ func TestMultipleBytes(t *testing.T) {
 	assert.Equal(t, byte(204), buf.Bytes()[1]) // 11001100 = 204
 	
 	// Buffer should be empty
-	assert.Equal(t, byte(0), bw.buffer)
+	assert.Equal(t, uint64(0), bw.acc)
 	assert.Equal(t, 0, bw.count)
+	assert.Empty(t, bw.buf)
 }
 
 func TestMixedWriteOperations(t *testing.T) {
@@ -154,6 +164,8 @@ func TestMixedWriteOperations(t *testing.T) {
 	// Write string of bits
 	err = bw.WriteBitsFromString("101010")
 	assert.NoError(t, err)
+	err = bw.Flush()
+	assert.NoError(t, err)
 	
 	// Should have written 1 byte (8 bits total)
 	assert.Equal(t, 1, buf.Len())
@@ -170,8 +182,122 @@ func TestBitOrdering(t *testing.T) {
 		err := bw.WriteBit(bit)
 		assert.NoError(t, err)
 	}
+	assert.NoError(t, bw.Flush())
 	
 	// Should result in 10000001 = 129
 	assert.Equal(t, 1, buf.Len())
 	assert.Equal(t, byte(129), buf.Bytes()[0])
-}
\ No newline at end of file
+}
+
+func TestWriteBits(t *testing.T) {
+	var buf bytes.Buffer
+	bw := NewBitWriter(&buf)
+
+	assert.NoError(t, bw.WriteBits(0b101, 3))
+	assert.NoError(t, bw.WriteBits(0xffff_0000_ffff_0000, 64))
+	assert.NoError(t, bw.WriteBits(0b1, 1))
+	assert.NoError(t, bw.WriteBits(0xff, 0))
+	assert.NoError(t, bw.Flush())
+
+	// 101, then the 64 bits, then 1 and four bits of padding
+	want := []byte{0b101_11111, 0xff, 0b111_00000, 0, 0b000_11111, 0xff, 0b111_00000, 0, 0b000_1_0000}
+	assert.Equal(t, want, buf.Bytes())
+}
+
+func TestWriteBitsMatchesWriteBit(t *testing.T) {
+	var bulk, single bytes.Buffer
+	bw, sw := NewBitWriter(&bulk), NewBitWriter(&single)
+	for i := 0; i < 10000; i++ {
+		value, n := uint64(i*2654435761), i%65
+		assert.NoError(t, bw.WriteBits(value, n))
+		for j := n - 1; j >= 0; j-- {
+			assert.NoError(t, sw.WriteBit(value&(1<<j) != 0))
+		}
+	}
+	assert.NoError(t, bw.Flush())
+	assert.NoError(t, sw.Flush())
+	assert.Equal(t, single.Bytes(), bulk.Bytes())
+}
+
+func BenchmarkWriteBitsFromString(b *testing.B) {
+	bw := NewBitWriter(io.Discard)
+	b.SetBytes(1 << 10)
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 1<<10; j++ {
+			bw.WriteBitsFromString("01101")
+			bw.WriteBitsFromString("110")
+		}
+	}
+}
+
+func BenchmarkWriteBits(b *testing.B) {
+	bw := NewBitWriter(io.Discard)
+	b.SetBytes(1 << 10)
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 1<<10; j++ {
+			bw.WriteBits(0b01101, 5)
+			bw.WriteBits(0b110, 3)
+		}
+	}
+}
+
+func TestLSBFirst(t *testing.T) {
+	var buf bytes.Buffer
+	bw := NewBitWriter(&buf, WithBitOrder(LSBFirst))
+
+	assert.NoError(t, bw.WriteBit(true))
+	assert.NoError(t, bw.WriteBits(0b10, 2))
+	assert.NoError(t, bw.WriteBits(0xabcd, 16))
+	assert.NoError(t, bw.Flush())
+	// Packed from the least significant bit up: 0xabcd<<3 | 0b10<<1 | 1
+	assert.Equal(t, []byte{0x6d, 0x5e, 0x05}, buf.Bytes())
+
+	// Whole bytes of a value come out little endian.
+	buf.Reset()
+	assert.NoError(t, bw.WriteBits(0x0123_4567_89ab_cdef, 64))
+	assert.NoError(t, bw.Flush())
+	assert.Equal(t, []byte{0xef, 0xcd, 0xab, 0x89, 0x67, 0x45, 0x23, 0x01}, buf.Bytes())
+}
+
+// TestLSBFirstDeflate writes a DEFLATE block with fixed codes, which
+// compress/flate must be able to read.
+func TestLSBFirstDeflate(t *testing.T) {
+	var buf bytes.Buffer
+	bw := NewBitWriter(&buf, WithBitOrder(LSBFirst))
+
+	// Final block, fixed codes.
+	assert.NoError(t, bw.WriteBits(1, 1))
+	assert.NoError(t, bw.WriteBits(0b01, 2))
+	for _, c := range []byte("deflate") {
+		// Literals up to 143 have the 8 bit codes 0x30 onwards. Huffman
+		// codes start with their most significant bit, so they go in
+		// reversed.
+		assert.NoError(t, bw.WriteBits(uint64(bits.Reverse8(0x30+c)), 8))
+	}
+	// End of block has the 7 bit code 0.
+	assert.NoError(t, bw.WriteBits(0, 7))
+	assert.NoError(t, bw.Flush())
+
+	decoded, err := io.ReadAll(flate.NewReader(&buf))
+	assert.NoError(t, err)
+	assert.Equal(t, "deflate", string(decoded))
+}
+
+func TestBitOrdersMirrorEachOther(t *testing.T) {
+	var msb, lsb bytes.Buffer
+	mw := NewBitWriter(&msb)
+	lw := NewBitWriter(&lsb, WithBitOrder(LSBFirst))
+	for i := 0; i < 1000; i++ {
+		bit := i%3 == 0 || i%7 == 0
+		assert.NoError(t, mw.WriteBit(bit))
+		assert.NoError(t, lw.WriteBit(bit))
+	}
+	assert.NoError(t, mw.Flush())
+	assert.NoError(t, lw.Flush())
+
+	// The same bits end up in the same bytes, in mirrored positions.
+	assert.Equal(t, msb.Len(), lsb.Len())
+	for i, b := range msb.Bytes() {
+		assert.Equal(t, bits.Reverse8(b), lsb.Bytes()[i])
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/Ninad-Bhangui/gohuffman/archive"
+)
+
+func createArchive(dir string, outputpath string) (err error) {
+	outputFile, err := createOutput(outputpath)
+	if err != nil {
+		return err
+	}
+	defer closeOutput(outputFile, &err)
+	aw, err := archive.NewWriter(outputFile)
+	if err != nil {
+		return err
+	}
+	// Like tar, leave out the archive itself if it is written inside dir.
+	outputInfo, err := outputFile.Stat()
+	if err != nil {
+		return err
+	}
+	err = aw.AddFSFunc(os.DirFS(dir), func(path string, info fs.FileInfo) bool {
+		if !os.SameFile(info, outputInfo) {
+			return false
+		}
+		fmt.Fprintf(os.Stderr, "%s: file is the archive; not added\n", path)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return aw.Close()
+}
+
+func openArchive(path string) (*archive.Reader, *os.File, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	ar, err := archive.NewReader(file, stat.Size())
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return ar, file, nil
+}
+
+func listArchive(path string) error {
+	ar, file, err := openArchive(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	for _, e := range ar.Entries {
+		_, err = fmt.Printf("%s %10d %s %s\n", e.Mode, e.Size, e.ModTime.Format("2006-01-02 15:04"), e.Path)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractArchive writes member, or every member if it is empty, below
+// outputDir. A single member can also go to stdout with an outputDir of -.
+func extractArchive(path string, outputDir string, member string) error {
+	ar, file, err := openArchive(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	entries := ar.Entries
+	if member != "" {
+		e, err := ar.Entry(member)
+		if err != nil {
+			return err
+		}
+		entries = []archive.Entry{e}
+		if outputDir == "-" {
+			return extractEntry(ar, e, os.Stdout)
+		}
+	}
+	for _, e := range entries {
+		err = extractFile(ar, e, filepath.Join(outputDir, filepath.FromSlash(e.Path)))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractFile(ar *archive.Reader, e archive.Entry, dest string) (err error) {
+	err = os.MkdirAll(filepath.Dir(dest), 0o755)
+	if err != nil {
+		return err
+	}
+	outputFile, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, e.Mode.Perm())
+	if err != nil {
+		return err
+	}
+	err = extractEntry(ar, e, outputFile)
+	closeOutput(outputFile, &err)
+	if err != nil {
+		return err
+	}
+	return os.Chtimes(dest, e.ModTime, e.ModTime)
+}
+
+func extractEntry(ar *archive.Reader, e archive.Entry, w io.Writer) error {
+	rc, err := ar.Open(e)
+	if err != nil {
+		return fmt.Errorf("%s: %w", e.Path, err)
+	}
+	defer rc.Close()
+	_, err = io.Copy(w, rc)
+	if err != nil {
+		return fmt.Errorf("%s: %w", e.Path, err)
+	}
+	return nil
+}
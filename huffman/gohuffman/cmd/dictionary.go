@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/Ninad-Bhangui/gohuffman/huffman"
+)
+
+// trainDictionary trains a dictionary on every file in paths, descending into
+// directories, and writes it to outputpath.
+func trainDictionary(paths []string, outputpath string, alphabet huffman.Alphabet) (err error) {
+	table := huffman.FreqTable{}
+	files := 0
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() {
+				return err
+			}
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			fileTable, err := huffman.CalculateFreqWith(file, alphabet)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			for symbol, freq := range fileTable {
+				table[symbol] += freq
+			}
+			files++
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	dict, err := huffman.NewDictionary(table, alphabet)
+	if err != nil {
+		return err
+	}
+
+	outputFile, err := createOutput(outputpath)
+	if err != nil {
+		return err
+	}
+	defer closeOutput(outputFile, &err)
+	err = huffman.WriteDictionary(outputFile, dict)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(info, "Trained dictionary %08x on %d files, %d symbols\n", dict.ID, files, dict.Trained)
+	return nil
+}
+
+func loadDictionary(path string) (*huffman.Dictionary, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	dict, err := huffman.ReadDictionary(bufio.NewReader(file))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return dict, nil
+}
+
+// verifyDictionary is -action verify for files coded with dict.
+func verifyDictionary(file *os.File, dict *huffman.Dictionary) error {
+	zr, err := huffman.NewReaderOptions(file, huffman.ReaderOptions{Dictionary: dict})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(io.Discard, zr)
+	if err != nil {
+		return err
+	}
+	if zr.Container().Flags&huffman.FlagChecksum == 0 {
+		fmt.Fprintln(info, "Decoded successfully, but the file has no checksum to verify")
+		return nil
+	}
+	fmt.Fprintln(info, "OK")
+	return nil
+}
@@ -1,56 +1,307 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"hash/crc32"
+	"io"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/Ninad-Bhangui/gohuffman/huffman"
 )
 
+// info receives progress messages. It moves to stderr when the output itself
+// or the -stats report goes to stdout.
+var info io.Writer = os.Stdout
+
+// report receives the -stats report, on stdout unless the output goes there.
+var report io.Writer = os.Stdout
+
+// Exit codes: exitError for failures in general, exitUsage for invalid
+// arguments and exitCorrupt when the compressed input is damaged.
+const (
+	exitError   = 1
+	exitUsage   = 2
+	exitCorrupt = 3
+)
+
+// errUsage makes main print the usage message.
+var errUsage = errors.New("invalid arguments")
+
 func main() {
-	filearg := flag.String("filepath", "", "filepath")
-	outputarg := flag.String("outputpath", "", "output path")
-	actionarg := flag.String("action", "encode", "encode/decode")
+	err := run()
+	if err == nil {
+		return
+	}
+	if err == errUsage {
+		flag.Usage()
+	} else {
+		fmt.Fprintln(os.Stderr, "gohuffman:", err)
+	}
+	os.Exit(exitCode(err))
+}
+
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, errUsage):
+		return exitUsage
+	case errors.Is(err, huffman.ErrCorruptHeader), errors.Is(err, huffman.ErrCorruptData),
+		errors.Is(err, huffman.ErrTruncatedData), errors.Is(err, huffman.ErrChecksumMismatch):
+		return exitCorrupt
+	}
+	return exitError
+}
+
+func run() (err error) {
+	filearg := flag.String("filepath", "", "filepath, - for stdin (for train a file or directory, more can follow as arguments)")
+	outputarg := flag.String("outputpath", "", "output path, - for stdout (not needed for verify and list)")
+	actionarg := flag.String("action", "encode", "encode/decode/verify, tree to export the code tree of a file, train to build a dictionary, or archive/list/extract for multi-file archives")
+	headerarg := flag.String("header", "freq", "freq/canonical/adaptive (encode only, decode reads it from the file; streaming always uses canonical, adaptive is single pass too)")
+	alphabetarg := flag.String("alphabet", "rune", "rune/byte, use byte for binary files (encode only)")
+	streamarg := flag.Bool("stream", false, "encode in a single pass using independent blocks, implied when reading stdin")
+	blocksizearg := flag.Int("blocksize", huffman.DefaultBlockSize, "bytes buffered per block when streaming, at most 268435456")
+	maxcodelenarg := flag.Int("maxcodelen", 0, "longest code allowed in bits, 0 for no limit (canonical and streaming only)")
+	workersarg := flag.Int("workers", 1, "blocks coded at once when streaming or decoding, more than 1 implies -stream")
+	memberarg := flag.String("member", "", "archive member to extract, all members if empty")
+	statsarg := flag.String("stats", "", "text/json, report sizes, entropy and per-symbol code lengths (two-pass encode only)")
+	treeformatarg := flag.String("treeformat", "dot", "dot/json, output format of -action tree")
+	dictarg := flag.String("dict", "", "dictionary file from -action train, to encode without a header and to decode such files")
+	rangearg := flag.String("range", "", "start:len, decode only that part of the original data (needs a file with a block index)")
 	flag.Parse()
 
-	if *filearg == "" || (*actionarg != "encode" && *actionarg != "decode") || *outputarg == "" {
-		flag.Usage()
-		os.Exit(1)
+	action := *actionarg
+	validAction := action == "encode" || action == "decode" || action == "verify" || action == "tree" || action == "train" ||
+		action == "archive" || action == "list" || action == "extract"
+	needsOutput := action != "verify" && action != "list"
+	if *filearg == "" || !validAction || (*outputarg == "" && needsOutput) ||
+		(*headerarg != "freq" && *headerarg != "canonical" && *headerarg != "adaptive") ||
+		(*alphabetarg != "rune" && *alphabetarg != "byte") || *blocksizearg <= 0 || *blocksizearg > huffman.DefaultMaxBlockSize || *maxcodelenarg < 0 || *workersarg < 1 ||
+		(*statsarg != "" && *statsarg != "text" && *statsarg != "json") ||
+		(*treeformatarg != "dot" && *treeformatarg != "json") {
+		return errUsage
 	}
 	filepath := *filearg
-	fmt.Println("Got filepath: ", filepath)
 	outputpath := *outputarg
-	fmt.Println("Got outputpath: ", outputpath)
+	if outputpath == "-" || action == "list" || *statsarg != "" {
+		info = os.Stderr
+	}
+	if outputpath == "-" {
+		report = os.Stderr
+	}
+	fmt.Fprintln(info, "Got filepath: ", filepath)
+	fmt.Fprintln(info, "Got outputpath: ", outputpath)
 
-	action := *actionarg
-	fmt.Println("Got action: ", action)
-	file, err := os.Open(filepath)
+	fmt.Fprintln(info, "Got action: ", action)
+	header := *headerarg
+	alphabet := huffman.RuneAlphabet
+	if *alphabetarg == "byte" {
+		alphabet = huffman.ByteAlphabet
+	}
+	switch action {
+	case "archive":
+		return createArchive(filepath, outputpath)
+	case "list":
+		return listArchive(filepath)
+	case "extract":
+		return extractArchive(filepath, outputpath, *memberarg)
+	case "train":
+		return trainDictionary(append([]string{filepath}, flag.Args()...), outputpath, alphabet)
+	}
+	var dict *huffman.Dictionary
+	if *dictarg != "" {
+		dict, err = loadDictionary(*dictarg)
+		if err != nil {
+			return err
+		}
+	}
+
+	file := os.Stdin
+	if filepath != "-" {
+		f, err := os.Open(filepath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		file = f
+	}
+
+	if action == "verify" && dict != nil {
+		return verifyDictionary(file, dict)
+	}
+	if action == "verify" {
+		err := huffman.Verify(file)
+		if errors.Is(err, huffman.ErrNoChecksum) {
+			fmt.Fprintln(info, "Decoded successfully, but the file has no checksum to verify")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(info, "OK")
+		return nil
+	}
+
+	opts := huffman.WriterOptions{
+		Alphabet:      alphabet,
+		BlockSize:     *blocksizearg,
+		MaxCodeLength: *maxcodelenarg,
+		Workers:       *workersarg,
+	}
+	stream := *streamarg || filepath == "-" || opts.Workers > 1
+	if action == "encode" && opts.MaxCodeLength > 0 && (header == "adaptive" || (!stream && header != "canonical")) {
+		return fmt.Errorf("%w: -maxcodelen needs -header canonical or streaming", errUsage)
+	}
+	if action == "encode" && *statsarg != "" && (header == "adaptive" || stream) {
+		return fmt.Errorf("%w: -stats needs the two-pass encoder, so not adaptive or streaming", errUsage)
+	}
+	var start, length int64
+	if action == "decode" && *rangearg != "" {
+		start, length, err = parseRange(*rangearg)
+		if err != nil {
+			return err
+		}
+	}
+
+	outputFile, err := createOutput(outputpath)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	defer closeOutput(outputFile, &err)
+
+	switch {
+	case action == "encode" && dict != nil:
+		return huffman.EncodeDictionary(file, outputFile, dict)
+	case action == "tree":
+		return exportTree(file, outputFile, header, opts, *treeformatarg)
+	case action == "encode" && header == "adaptive":
+		return huffman.EncodeAdaptive(file, outputFile, alphabet)
+	case action == "encode" && stream:
+		return huffman.EncodeStream(file, outputFile, opts)
+	case action == "encode":
+		return encode(file, outputFile, header, opts, *statsarg)
+	case *rangearg != "":
+		return decodeRange(file, outputFile, start, length)
 	}
-	defer file.Close()
-	outputFile, err := os.Create(outputpath)
+	zr, err := huffman.NewReaderOptions(file, huffman.ReaderOptions{Workers: opts.Workers, Dictionary: dict})
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	defer outputFile.Close()
-	if action == "encode" {
-		table := huffman.CalculateFreq(file)
-		tree := huffman.CreateTree(table)
-		encodedMap := tree.BuildEncodingMap()
-		fmt.Println(encodedMap)
+	defer zr.Close()
+	_, err = io.Copy(outputFile, zr)
+	return err
+}
 
-		huffman.WriteHeader(outputFile, table)
-		file.Seek(0, 0)
-		huffman.WriteData(file, outputFile, encodedMap)
-	} else {
-		frequency_table, charCount, err := huffman.ReadHeader(file)
-		if err != nil {
-			log.Fatal(err)
+// createOutput opens path for writing, or returns stdout for -.
+func createOutput(path string) (*os.File, error) {
+	if path == "-" {
+		return os.Stdout, nil
+	}
+	return os.Create(path)
+}
+
+// closeOutput closes a file from createOutput, reporting the error through
+// err unless an earlier one is there already.
+func closeOutput(f *os.File, err *error) {
+	if f == os.Stdout {
+		return
+	}
+	closeErr := f.Close()
+	if *err == nil {
+		*err = closeErr
+	}
+}
+
+// encode codes file with a single tree in two passes, and prints statistics
+// about the code if statsFormat is set.
+func encode(file *os.File, outputFile *os.File, header string, opts huffman.WriterOptions, statsFormat string) error {
+	alphabet := opts.Alphabet
+	crc := crc32.NewIEEE()
+	original := &countingWriter{w: crc}
+	table, err := huffman.CalculateFreqWith(io.TeeReader(file, original), alphabet)
+	if err != nil {
+		return err
+	}
+	tree := huffman.CreateTree(table)
+	encodedMap := tree.BuildEncodingMap()
+	lengths := tree.CodeLengths()
+	container := huffman.Container{
+		HeaderType: huffman.HeaderFreq,
+		Flags:      huffman.FlagChecksum,
+		Checksum:   crc.Sum32(),
+	}
+	if header == "canonical" {
+		container.HeaderType = huffman.HeaderCanonical
+		if opts.MaxCodeLength > 0 {
+			lengths, err = huffman.LimitedCodeLengths(table, opts.MaxCodeLength)
+			if err != nil {
+				return err
+			}
 		}
-		tree := huffman.CreateTree(frequency_table)
-		huffman.DecodeAndWriteData(file, outputFile, tree, charCount)
+		encodedMap = lengths.BuildEncodingMap()
+	}
+	if alphabet == huffman.ByteAlphabet {
+		container.Flags |= huffman.FlagByteAlphabet
+	}
+	out := &countingWriter{w: outputFile}
+	err = huffman.WriteContainer(out, container)
+	if err != nil {
+		return err
+	}
+	if header == "canonical" {
+		err = huffman.WriteCanonicalHeader(out, lengths, table.CharCount())
+	} else {
+		err = huffman.WriteHeader(out, table)
+	}
+	if err != nil {
+		return err
+	}
+	headerSize := out.n
+
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	err = huffman.WriteDataWith(file, out, encodedMap, alphabet)
+	if err != nil {
+		return err
+	}
+
+	if statsFormat != "" {
+		stats := huffman.NewStats(table, lengths)
+		stats.SetSizes(original.n, out.n, headerSize)
+		return printStats(report, stats, alphabet, statsFormat)
+	}
+	return nil
+}
+
+func parseRange(s string) (int64, int64, error) {
+	startStr, lengthStr, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, 0, fmt.Errorf("%w: range %q, want start:len", errUsage, s)
+	}
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, fmt.Errorf("%w: range start %q", errUsage, startStr)
+	}
+	length, err := strconv.ParseInt(lengthStr, 10, 64)
+	if err != nil || length < 0 {
+		return 0, 0, fmt.Errorf("%w: range length %q", errUsage, lengthStr)
+	}
+	return start, length, nil
+}
+
+func decodeRange(file *os.File, outputFile *os.File, start int64, length int64) error {
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	ra, err := huffman.NewReaderAt(file, stat.Size())
+	if err != nil {
+		return err
 	}
+	_, err = io.Copy(outputFile, io.NewSectionReader(ra, start, length))
+	return err
 }
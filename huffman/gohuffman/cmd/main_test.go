@@ -1,9 +1,14 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -11,10 +16,31 @@ import (
 	"github.com/Ninad-Bhangui/gohuffman/huffman"
 )
 
+// TestMain runs the command itself instead of the tests when asked to, so
+// tests can check what it prints.
+func TestMain(m *testing.M) {
+	if os.Getenv("GOHUFFMAN_RUN_MAIN") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func runMain(t *testing.T, args ...string) (stdout, stderr []byte) {
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "GOHUFFMAN_RUN_MAIN=1")
+	var outBuf, errBuf bytes.Buffer
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+	assert.NoError(t, cmd.Run(), errBuf.String())
+	return outBuf.Bytes(), errBuf.Bytes()
+}
+
 func TestCalculateFreq(t *testing.T) {
 	input := `aaaee1`
 
-	output := huffman.CalculateFreq(strings.NewReader(input))
+	output, err := huffman.CalculateFreq(strings.NewReader(input))
+	assert.NoError(t, err)
 	assert.Equal(t, 2, output['e'])
 	assert.Equal(t, 3, output['a'])
 	assert.Equal(t, 1, output['1'])
@@ -23,7 +49,8 @@ func TestCalculateFreq(t *testing.T) {
 func TestGetEncodingMap(t *testing.T) {
 	input := `aaaee1`
 
-	output := huffman.CalculateFreq(strings.NewReader(input))
+	output, err := huffman.CalculateFreq(strings.NewReader(input))
+	assert.NoError(t, err)
 	tree := huffman.CreateTree(output)
 	encodedMap := tree.BuildEncodingMap()
 	assert.Equal(t, 3, len(encodedMap))
@@ -39,7 +66,90 @@ func TestCalculateWithFile(t *testing.T) {
 		log.Fatal(err)
 	}
 	defer file.Close()
-	output := huffman.CalculateFreq(file)
+	output, err := huffman.CalculateFreq(file)
+	assert.NoError(t, err)
 	assert.Equal(t, 333, output['X'])
 	assert.Equal(t, 223000, output['t'])
 }
+
+func TestEncodeDegenerateInputs(t *testing.T) {
+	inputs := map[string]string{
+		"empty":         "",
+		"single symbol": "a",
+		"single run":    strings.Repeat("a", 100),
+	}
+	for name, input := range inputs {
+		for _, header := range []string{"freq", "canonical"} {
+			t.Run(name+"/"+header, func(t *testing.T) {
+				dir := t.TempDir()
+				inputPath := filepath.Join(dir, "input.txt")
+				assert.NoError(t, os.WriteFile(inputPath, []byte(input), 0o644))
+				file, err := os.Open(inputPath)
+				assert.NoError(t, err)
+				defer file.Close()
+				outputFile, err := os.Create(filepath.Join(dir, "input.huf"))
+				assert.NoError(t, err)
+				defer outputFile.Close()
+
+				opts := huffman.WriterOptions{Alphabet: huffman.RuneAlphabet}
+				assert.NoError(t, encode(file, outputFile, header, opts, ""))
+				_, err = outputFile.Seek(0, io.SeekStart)
+				assert.NoError(t, err)
+				var decoded bytes.Buffer
+				assert.NoError(t, huffman.Decode(outputFile, &decoded))
+				assert.Equal(t, input, decoded.String())
+			})
+		}
+	}
+}
+
+func TestEncodeRejectsInvalidUTF8(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.bin")
+	assert.NoError(t, os.WriteFile(inputPath, []byte{'a', 0xff, 0x00, 'b'}, 0o644))
+	file, err := os.Open(inputPath)
+	assert.NoError(t, err)
+	defer file.Close()
+	outputFile, err := os.Create(filepath.Join(dir, "input.huf"))
+	assert.NoError(t, err)
+	defer outputFile.Close()
+
+	opts := huffman.WriterOptions{Alphabet: huffman.RuneAlphabet}
+	err = encode(file, outputFile, "freq", opts, "")
+	assert.ErrorIs(t, err, huffman.ErrInvalidUTF8)
+	assert.Equal(t, exitError, exitCode(err))
+}
+
+func TestArchiveSkipsItself(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello"), 0o644))
+	outputPath := filepath.Join(dir, "self.ghar")
+	assert.NoError(t, createArchive(dir, outputPath))
+
+	ar, file, err := openArchive(outputPath)
+	assert.NoError(t, err)
+	defer file.Close()
+	assert.Len(t, ar.Entries, 1)
+	assert.Equal(t, "a.txt", ar.Entries[0].Path)
+}
+
+func TestStatsJSONIsAloneOnStdout(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.txt")
+	assert.NoError(t, os.WriteFile(inputPath, []byte("abracadabra"), 0o644))
+
+	stdout, stderr := runMain(t, "-filepath", inputPath, "-outputpath", filepath.Join(dir, "input.huf"), "-stats", "json")
+	var stats huffman.Stats
+	assert.NoError(t, json.Unmarshal(stdout, &stats), string(stdout))
+	assert.Equal(t, int64(11), stats.OriginalSize)
+	assert.Contains(t, string(stderr), "Got action")
+}
+
+func TestExitCode(t *testing.T) {
+	assert.Equal(t, exitUsage, exitCode(errUsage))
+	assert.Equal(t, exitUsage, exitCode(fmt.Errorf("%w: -range", errUsage)))
+	assert.Equal(t, exitCorrupt, exitCode(huffman.ErrChecksumMismatch))
+	assert.Equal(t, exitCorrupt, exitCode(huffman.ErrTruncatedData))
+	assert.Equal(t, exitCorrupt, exitCode(huffman.ErrInvalidCodeLengths))
+	assert.Equal(t, exitError, exitCode(os.ErrNotExist))
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/Ninad-Bhangui/gohuffman/huffman"
+)
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+func printStats(w io.Writer, stats huffman.Stats, alphabet huffman.Alphabet, format string) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(stats)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "original size:\t%d bytes\n", stats.OriginalSize)
+	fmt.Fprintf(tw, "compressed size:\t%d bytes\n", stats.CompressedSize)
+	fmt.Fprintf(tw, "header size:\t%d bytes\n", stats.HeaderSize)
+	fmt.Fprintf(tw, "ratio:\t%.2f%%\n", stats.Ratio*100)
+	fmt.Fprintf(tw, "entropy:\t%.4f bits/symbol\n", stats.Entropy)
+	fmt.Fprintf(tw, "average code length:\t%.4f bits/symbol (%.4f above entropy)\n",
+		stats.AverageCodeLength, stats.AverageCodeLength-stats.Entropy)
+	err := tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "symbol\tfreq\tlength\t")
+	for _, sym := range stats.Symbols {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t\n", alphabet.SymbolString(sym.Symbol), sym.Freq, sym.CodeLength)
+	}
+	return tw.Flush()
+}
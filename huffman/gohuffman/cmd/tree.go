@@ -0,0 +1,35 @@
+package main
+
+import (
+	"io"
+
+	"github.com/Ninad-Bhangui/gohuffman/huffman"
+)
+
+// exportTree writes the tree the two-pass encoder would build for file, as
+// DOT or JSON.
+func exportTree(file io.Reader, outputFile io.Writer, header string, opts huffman.WriterOptions, format string) error {
+	table, err := huffman.CalculateFreqWith(file, opts.Alphabet)
+	if err != nil {
+		return err
+	}
+	tree := huffman.CreateTree(table)
+	if header == "canonical" {
+		lengths := tree.CodeLengths()
+		if opts.MaxCodeLength > 0 {
+			lengths, err = huffman.LimitedCodeLengths(table, opts.MaxCodeLength)
+			if err != nil {
+				return err
+			}
+		}
+		tree, err = huffman.CreateCanonicalTree(lengths)
+		if err != nil {
+			return err
+		}
+	}
+
+	if format == "json" {
+		return tree.WriteJSON(outputFile, opts.Alphabet)
+	}
+	return tree.WriteDOT(outputFile, opts.Alphabet)
+}
@@ -0,0 +1,314 @@
+package huffman
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/Ninad-Bhangui/gohuffman/bitreader"
+	"github.com/Ninad-Bhangui/gohuffman/bitwriter"
+)
+
+// adaptiveNode is a node of the FGK tree. Leaves have no children, and the
+// NYT ("not yet transmitted") leaf stands for every symbol not seen so far.
+type adaptiveNode struct {
+	weight int
+	parent int
+	left   int
+	right  int
+	symbol rune
+	// pos is the node's place in adaptiveTree.order.
+	pos int
+}
+
+// adaptiveTree is an FGK adaptive Huffman tree. Encoder and decoder start from
+// the same empty tree and update it after every symbol, so no frequency
+// header is needed.
+type adaptiveTree struct {
+	nodes []adaptiveNode
+	// order lists the nodes by decreasing node number, root first. Weights
+	// never increase along it, which is the sibling property FGK keeps.
+	order []int
+	// leaders maps every weight to the position in order of the first node,
+	// the block leader, with that weight.
+	leaders map[int]int
+	leaves  map[rune]int
+	nyt     int
+}
+
+func newAdaptiveTree() *adaptiveTree {
+	t := &adaptiveTree{leaders: map[int]int{0: 0}, leaves: map[rune]int{}}
+	t.nyt = t.addNode(-1, 0)
+	return t
+}
+
+func (t *adaptiveTree) addNode(parent int, symbol rune) int {
+	id := len(t.nodes)
+	t.nodes = append(t.nodes, adaptiveNode{parent: parent, left: -1, right: -1, symbol: symbol, pos: len(t.order)})
+	t.order = append(t.order, id)
+	return id
+}
+
+func (t *adaptiveTree) isLeaf(node int) bool {
+	return t.nodes[node].left == -1
+}
+
+// split turns the NYT leaf into an internal node whose children are a new NYT
+// leaf and a leaf for symbol, and returns the new symbol leaf.
+func (t *adaptiveTree) split(symbol rune) int {
+	old := t.nyt
+	leaf := t.addNode(old, symbol)
+	t.nyt = t.addNode(old, 0)
+	t.nodes[old].left = t.nyt
+	t.nodes[old].right = leaf
+	t.leaves[symbol] = leaf
+	return leaf
+}
+
+// leader returns the highest numbered node with the same weight as node.
+func (t *adaptiveTree) leader(node int) int {
+	return t.order[t.leaders[t.nodes[node].weight]]
+}
+
+// increment adds one to the weight of node, which is the leader of its block
+// or, when that is its parent, right behind it. In that case the parent is
+// incremented next, so node is already out of the way when the leader of the
+// rest of the block is looked for.
+func (t *adaptiveTree) increment(node int) {
+	pos, weight := t.nodes[node].pos, t.nodes[node].weight
+	if t.leaders[weight] == pos {
+		next := pos + 1
+		if next < len(t.order) && t.nodes[t.order[next]].weight > weight {
+			next++
+		}
+		if next < len(t.order) && t.nodes[t.order[next]].weight == weight {
+			t.leaders[weight] = next
+		} else {
+			delete(t.leaders, weight)
+		}
+	}
+	if leader, ok := t.leaders[weight+1]; !ok || leader > pos {
+		t.leaders[weight+1] = pos
+	}
+	t.nodes[node].weight++
+}
+
+// swap exchanges the subtrees rooted at a and b.
+func (t *adaptiveTree) swap(a, b int) {
+	pa, pb := t.nodes[a].parent, t.nodes[b].parent
+	if pa == pb {
+		parent := &t.nodes[pa]
+		parent.left, parent.right = parent.right, parent.left
+	} else {
+		t.replaceChild(pa, a, b)
+		t.replaceChild(pb, b, a)
+		t.nodes[a].parent, t.nodes[b].parent = pb, pa
+	}
+	posA, posB := t.nodes[a].pos, t.nodes[b].pos
+	t.order[posA], t.order[posB] = b, a
+	t.nodes[a].pos, t.nodes[b].pos = posB, posA
+}
+
+func (t *adaptiveTree) replaceChild(parent, old, new int) {
+	if t.nodes[parent].left == old {
+		t.nodes[parent].left = new
+	} else {
+		t.nodes[parent].right = new
+	}
+}
+
+// update counts one more occurrence of symbol and restores the sibling
+// property on the way up to the root.
+func (t *adaptiveTree) update(symbol rune) {
+	node, ok := t.leaves[symbol]
+	if !ok {
+		node = t.split(symbol)
+	}
+	for node != -1 {
+		leader := t.leader(node)
+		if leader != node && leader != t.nodes[node].parent {
+			t.swap(node, leader)
+		}
+		t.increment(node)
+		node = t.nodes[node].parent
+	}
+}
+
+// path appends the code of node to bits, root first.
+func (t *adaptiveTree) path(node int, bits []bool) []bool {
+	start := len(bits)
+	for parent := t.nodes[node].parent; parent != -1; node, parent = parent, t.nodes[parent].parent {
+		bits = append(bits, t.nodes[parent].right == node)
+	}
+	for i, j := start, len(bits)-1; i < j; i, j = i+1, j-1 {
+		bits[i], bits[j] = bits[j], bits[i]
+	}
+	return bits
+}
+
+// symbolBits is how many bits a symbol is sent with the first time it occurs.
+func (a Alphabet) symbolBits() int {
+	if a == ByteAlphabet {
+		return 8
+	}
+	return 21
+}
+
+// WriteAdaptiveData codes reader in a single pass with an adaptive Huffman
+// code. A symbol seen for the first time is sent as the NYT code, a zero bit
+// and the raw symbol. The NYT code followed by a one bit ends the data.
+func WriteAdaptiveData(reader io.Reader, writer io.Writer, alphabet Alphabet) error {
+	bw := bitwriter.NewBitWriter(writer)
+	tree := newAdaptiveTree()
+	scanner := bufio.NewScanner(reader)
+	scanner.Split(alphabet.splitFunc())
+
+	var bits []bool
+	for scanner.Scan() {
+		symbol := alphabet.symbol(scanner.Bytes())
+		bits = bits[:0]
+		if leaf, ok := tree.leaves[symbol]; ok {
+			bits = tree.path(leaf, bits)
+		} else {
+			bits = tree.path(tree.nyt, bits)
+			bits = append(bits, false)
+			for i := alphabet.symbolBits() - 1; i >= 0; i-- {
+				bits = append(bits, symbol&(1<<i) != 0)
+			}
+		}
+		for _, bit := range bits {
+			err := bw.WriteBit(bit)
+			if err != nil {
+				return err
+			}
+		}
+		tree.update(symbol)
+	}
+	err := scanner.Err()
+	if err != nil {
+		return err
+	}
+	for _, bit := range append(tree.path(tree.nyt, nil), true) {
+		err := bw.WriteBit(bit)
+		if err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func DecodeAdaptiveData(reader io.Reader, writer io.Writer, alphabet Alphabet) error {
+	br := bitreader.NewBitReader(reader)
+	return decodeAdaptive(&br, writer, alphabet, DecoderOptions{})
+}
+
+// decodeAdaptive decodes until the end marker. With no header to check up
+// front, the limits of opts are enforced as new symbols and output come in.
+func decodeAdaptive(br *bitreader.BitReader, writer io.Writer, alphabet Alphabet, opts DecoderOptions) error {
+	w := bufio.NewWriter(writer)
+	tree := newAdaptiveTree()
+	var out []byte
+	for decoded := 0; ; decoded++ {
+		node := tree.order[0]
+		for !tree.isLeaf(node) {
+			bit, err := br.ReadBit()
+			if err != nil {
+				return unexpectedEOF(err)
+			}
+			if bit {
+				node = tree.nodes[node].right
+			} else {
+				node = tree.nodes[node].left
+			}
+		}
+
+		symbol := tree.nodes[node].symbol
+		if node == tree.nyt {
+			end, err := br.ReadBit()
+			if err != nil {
+				return unexpectedEOF(err)
+			}
+			if end {
+				return w.Flush()
+			}
+			symbol = 0
+			for i := 0; i < alphabet.symbolBits(); i++ {
+				bit, err := br.ReadBit()
+				if err != nil {
+					return unexpectedEOF(err)
+				}
+				symbol <<= 1
+				if bit {
+					symbol |= 1
+				}
+			}
+			if _, seen := tree.leaves[symbol]; seen || !alphabet.validSymbol(symbol) {
+				return errInvalidCode
+			}
+			if len(tree.leaves) == opts.maxSymbols() {
+				return fmt.Errorf("%w: more than %d symbols", ErrLimitExceeded, len(tree.leaves))
+			}
+		}
+		if opts.MaxCharCount > 0 && decoded == opts.MaxCharCount {
+			return fmt.Errorf("%w: more than %d symbols decoded", ErrLimitExceeded, decoded)
+		}
+
+		out = alphabet.appendSymbol(out[:0], symbol)
+		_, err := w.Write(out)
+		if err != nil {
+			return err
+		}
+		tree.update(symbol)
+	}
+}
+
+// EncodeAdaptive writes a container holding r coded with WriteAdaptiveData.
+// The CRC-32 of r follows the coded data, since it is only known at the end.
+func EncodeAdaptive(r io.Reader, w io.Writer, alphabet Alphabet) error {
+	c := Container{HeaderType: HeaderAdaptive, Flags: FlagChecksum}
+	if alphabet == ByteAlphabet {
+		c.Flags |= FlagByteAlphabet
+	}
+	err := WriteContainer(w, c)
+	if err != nil {
+		return err
+	}
+	crc := crc32.NewIEEE()
+	err = WriteAdaptiveData(io.TeeReader(r, crc), w, alphabet)
+	if err != nil {
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, crc.Sum32())
+}
+
+func decodeAdaptiveBody(r io.Reader, w io.Writer, c Container, opts DecoderOptions) error {
+	br := bitreader.NewBitReader(r)
+	if c.Flags&FlagChecksum == 0 {
+		return decodeAdaptive(&br, w, c.Alphabet(), opts)
+	}
+
+	crc := crc32.NewIEEE()
+	err := decodeAdaptive(&br, io.MultiWriter(w, crc), c.Alphabet(), opts)
+	if err != nil {
+		return err
+	}
+	br.Align()
+	var checksum uint32
+	for i := 0; i < 32; i++ {
+		bit, err := br.ReadBit()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if bit {
+			// The checksum is little endian while bits come most significant
+			// first within each byte.
+			checksum |= 1 << (i/8*8 + 7 - i%8)
+		}
+	}
+	if checksum != crc.Sum32() {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package huffman
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdaptiveRoundtrip(t *testing.T) {
+	var distinct strings.Builder
+	for r := rune(0x4e00); r < 0x4e00+5000; r++ {
+		distinct.WriteRune(r)
+	}
+	inputs := []string{
+		"",
+		"a",
+		"aaaaaaaa",
+		"abracadabra",
+		"hello world! this is a test message with various characters 123 ünïcödé 日本語",
+		strings.Repeat("the quick brown fox jumps over the lazy dog ", 50),
+		strings.Repeat(distinct.String(), 2),
+	}
+	for _, input := range inputs {
+		for _, alphabet := range []Alphabet{RuneAlphabet, ByteAlphabet} {
+			var encoded bytes.Buffer
+			err := WriteAdaptiveData(strings.NewReader(input), &encoded, alphabet)
+			assert.NoError(t, err)
+
+			var decoded bytes.Buffer
+			err = DecodeAdaptiveData(&encoded, &decoded, alphabet)
+			assert.NoError(t, err)
+			assert.Equal(t, input, decoded.String())
+		}
+	}
+}
+
+func TestAdaptiveTreeKeepsSiblingProperty(t *testing.T) {
+	tree := newAdaptiveTree()
+	for _, r := range "mississippi river banks" {
+		tree.update(r)
+		for pos := 1; pos < len(tree.order); pos++ {
+			assert.GreaterOrEqual(t, tree.nodes[tree.order[pos-1]].weight, tree.nodes[tree.order[pos]].weight)
+		}
+		leaders := map[int]int{}
+		for pos, id := range tree.order {
+			if _, ok := leaders[tree.nodes[id].weight]; !ok {
+				leaders[tree.nodes[id].weight] = pos
+			}
+		}
+		assert.Equal(t, leaders, tree.leaders)
+		for id, node := range tree.nodes {
+			if !tree.isLeaf(id) {
+				assert.Equal(t, node.weight, tree.nodes[node.left].weight+tree.nodes[node.right].weight)
+			}
+		}
+	}
+	assert.Equal(t, 23, tree.nodes[tree.order[0]].weight)
+}
+
+func TestAdaptiveCompressesSample(t *testing.T) {
+	data, err := os.ReadFile("../samples/test.txt")
+	assert.NoError(t, err)
+	data = data[:100000]
+
+	var encoded bytes.Buffer
+	assert.NoError(t, EncodeAdaptive(bytes.NewReader(data), &encoded, RuneAlphabet))
+	assert.Less(t, encoded.Len(), len(data)*2/3)
+
+	var decoded bytes.Buffer
+	assert.NoError(t, Decode(&encoded, &decoded))
+	assert.Equal(t, data, decoded.Bytes())
+}
+
+func TestDecodeAdaptiveContainerDetectsCorruption(t *testing.T) {
+	var encoded bytes.Buffer
+	assert.NoError(t, EncodeAdaptive(strings.NewReader(containerTestText), &encoded, ByteAlphabet))
+	data := encoded.Bytes()
+	assert.NoError(t, Verify(bytes.NewReader(data)))
+
+	corrupted := bytes.Clone(data)
+	corrupted[len(corrupted)-1] ^= 0x01
+	assert.ErrorIs(t, Decode(bytes.NewReader(corrupted), io.Discard), ErrChecksumMismatch)
+
+	err := Decode(bytes.NewReader(data[:len(data)-6]), io.Discard)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,237 @@
+package huffman
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// maxCodeLength is the longest code a canonical header may describe.
+const maxCodeLength = 63
+
+// CodeLengths maps every symbol to the length of its code. Canonical codes are
+// derived from the lengths alone, so they are all a header needs to carry.
+type CodeLengths map[rune]int
+
+func (t Tree) CodeLengths() CodeLengths {
+	lengths := CodeLengths{}
+	for r, code := range t.BuildEncodingMap() {
+		lengths[r] = len(code)
+	}
+	return lengths
+}
+
+// sortedSymbols orders symbols by code length and then by symbol value, which
+// is the order canonical codes are handed out in.
+func (lengths CodeLengths) sortedSymbols() []rune {
+	symbols := make([]rune, 0, len(lengths))
+	for r := range lengths {
+		symbols = append(symbols, r)
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		li, lj := lengths[symbols[i]], lengths[symbols[j]]
+		if li == lj {
+			return symbols[i] < symbols[j]
+		}
+		return li < lj
+	})
+	return symbols
+}
+
+func (lengths CodeLengths) BuildEncodingMap() map[rune]string {
+	encodedMap := make(map[rune]string, len(lengths))
+	code := uint64(0)
+	prevLen := 0
+	for i, r := range lengths.sortedSymbols() {
+		length := lengths[r]
+		if i > 0 {
+			code++
+		}
+		code <<= length - prevLen
+		prevLen = length
+		encodedMap[r] = codeString(code, length)
+	}
+	return encodedMap
+}
+
+func codeString(code uint64, length int) string {
+	bits := make([]byte, length)
+	for i := 0; i < length; i++ {
+		if code&(1<<(length-1-i)) != 0 {
+			bits[i] = '1'
+		} else {
+			bits[i] = '0'
+		}
+	}
+	return string(bits)
+}
+
+// validate checks that the lengths describe a complete prefix code, i.e. that
+// the Kraft sum is exactly one. A lone symbol has a zero length code, and no
+// symbols at all is valid for empty data.
+func (lengths CodeLengths) validate() error {
+	if len(lengths) == 0 {
+		return nil
+	}
+	if len(lengths) == 1 {
+		for _, length := range lengths {
+			if length != 0 {
+				return ErrInvalidCodeLengths
+			}
+		}
+		return nil
+	}
+	maxLen := 0
+	for _, length := range lengths {
+		if length < 1 || length > maxCodeLength {
+			return ErrInvalidCodeLengths
+		}
+		maxLen = max(maxLen, length)
+	}
+	// Count in units of 2^-maxLen so the sum stays exact.
+	kraft, total := uint64(0), uint64(1)<<maxLen
+	for _, length := range lengths {
+		kraft += 1 << (maxLen - length)
+		if kraft > total {
+			return ErrInvalidCodeLengths
+		}
+	}
+	if kraft != total {
+		return ErrInvalidCodeLengths
+	}
+	return nil
+}
+
+// CreateCanonicalTree rebuilds the decoding tree for the canonical codes
+// described by lengths.
+func CreateCanonicalTree(lengths CodeLengths) (Tree, error) {
+	if err := lengths.validate(); err != nil {
+		return Tree{}, err
+	}
+	if len(lengths) == 0 {
+		return Tree{}, nil
+	}
+	encodedMap := lengths.BuildEncodingMap()
+	for r, code := range encodedMap {
+		if code == "" {
+			return Tree{Root: &LeafNode{Element: r}}, nil
+		}
+	}
+	root := &InternalNode{}
+	for r, code := range encodedMap {
+		node := root
+		for i, bit := range code {
+			last := i == len(code)-1
+			child := &node.Left
+			if bit == '1' {
+				child = &node.Right
+			}
+			if last {
+				*child = &LeafNode{Element: r}
+				break
+			}
+			if *child == nil {
+				*child = &InternalNode{}
+			}
+			node = (*child).(*InternalNode)
+		}
+	}
+	return Tree{Root: root}, nil
+}
+
+// WriteCanonicalHeader writes the symbol count followed by every symbol and
+// its code length. Symbols are written in ascending order as deltas from the
+// previous symbol so neighbouring code points stay small. The symbol count
+// may not exceed math.MaxInt32, like in a frequency header, or it fails with
+// ErrInputTooLarge.
+func WriteCanonicalHeader(w io.Writer, lengths CodeLengths, charCount int) error {
+	if charCount > math.MaxInt32 {
+		return fmt.Errorf("%w: %d symbols", ErrInputTooLarge, charCount)
+	}
+	symbols := make([]rune, 0, len(lengths))
+	for r := range lengths {
+		symbols = append(symbols, r)
+	}
+	sort.Slice(symbols, func(i, j int) bool { return symbols[i] < symbols[j] })
+
+	buf := binary.AppendUvarint(nil, uint64(len(symbols)))
+	buf = binary.AppendUvarint(buf, uint64(charCount))
+	prev := rune(0)
+	for _, r := range symbols {
+		buf = binary.AppendUvarint(buf, uint64(r-prev))
+		buf = append(buf, byte(lengths[r]))
+		prev = r
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+func ReadCanonicalHeader(r io.Reader) (CodeLengths, int, error) {
+	return ReadCanonicalHeaderWith(r, DecoderOptions{})
+}
+
+// ReadCanonicalHeaderWith reads a canonical header. The lengths still need
+// to be checked, which CreateCanonicalTree does.
+func ReadCanonicalHeaderWith(r io.Reader, opts DecoderOptions) (CodeLengths, int, error) {
+	br := newByteReader(r)
+	entryCount, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, 0, headerError(err)
+	}
+	charCount, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, 0, headerError(err)
+	}
+	if entryCount > uint64(opts.maxSymbols()) {
+		return nil, 0, fmt.Errorf("%w: %d symbols", ErrLimitExceeded, entryCount)
+	}
+	if charCount > math.MaxInt32 {
+		return nil, 0, fmt.Errorf("%w: %d symbols announced", ErrCorruptHeader, charCount)
+	}
+	err = opts.checkCharCount(int(charCount))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	lengths := CodeLengths{}
+	prev := rune(0)
+	for i := uint64(0); i < entryCount; i++ {
+		delta, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, 0, headerError(err)
+		}
+		length, err := br.ReadByte()
+		if err != nil {
+			return nil, 0, headerError(err)
+		}
+		// Symbols are sorted, so only the first may repeat the previous
+		// value of zero.
+		if (i > 0 && delta == 0) || delta > DefaultMaxSymbols || int64(prev)+int64(delta) >= DefaultMaxSymbols {
+			return nil, 0, fmt.Errorf("%w: symbol out of order or range", ErrCorruptHeader)
+		}
+		prev += rune(delta)
+		lengths[prev] = int(length)
+	}
+	return lengths, int(charCount), nil
+}
+
+// byteReader reads one byte at a time so that nothing past the header is
+// consumed from the underlying reader.
+type byteReader struct {
+	io.Reader
+}
+
+func newByteReader(r io.Reader) io.ByteReader {
+	if br, ok := r.(io.ByteReader); ok {
+		return br
+	}
+	return byteReader{r}
+}
+
+func (br byteReader) ReadByte() (byte, error) {
+	var buf [1]byte
+	_, err := io.ReadFull(br.Reader, buf[:])
+	return buf[0], err
+}
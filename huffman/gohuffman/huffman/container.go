@@ -0,0 +1,260 @@
+package huffman
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"unicode/utf8"
+)
+
+// Magic starts every container written by this package.
+var Magic = [4]byte{'G', 'H', 'U', 'F'}
+
+const FormatVersion = 1
+
+type HeaderType uint8
+
+const (
+	// HeaderLegacy marks files written before the container existed: a bare
+	// frequency header followed by the data. It is never written.
+	HeaderLegacy HeaderType = iota
+	HeaderFreq
+	HeaderCanonical
+	// HeaderAdaptive has no header at all, see WriteAdaptiveData.
+	HeaderAdaptive
+	// HeaderDictionary only names the dictionary the data was coded with,
+	// see EncodeDictionary.
+	HeaderDictionary
+)
+
+func (h HeaderType) String() string {
+	switch h {
+	case HeaderLegacy:
+		return "legacy"
+	case HeaderFreq:
+		return "freq"
+	case HeaderCanonical:
+		return "canonical"
+	case HeaderAdaptive:
+		return "adaptive"
+	case HeaderDictionary:
+		return "dictionary"
+	}
+	return fmt.Sprintf("HeaderType(%d)", uint8(h))
+}
+
+const (
+	// FlagChecksum means a CRC-32 (IEEE) of the original data follows the
+	// container fields. It precedes every block instead if FlagBlocks is set,
+	// and follows the data for HeaderAdaptive and HeaderDictionary.
+	FlagChecksum uint8 = 1 << iota
+	// FlagByteAlphabet means the symbols are raw bytes instead of runes.
+	FlagByteAlphabet
+	// FlagBlocks means the data is a sequence of independently coded blocks,
+	// see EncodeStream.
+	FlagBlocks
+	// FlagIndex means an index of the blocks follows the end of the stream.
+	FlagIndex
+)
+
+// knownFlags holds every flag bit this version understands.
+const knownFlags = FlagChecksum | FlagByteAlphabet | FlagBlocks | FlagIndex
+
+type Container struct {
+	Version    uint8
+	Flags      uint8
+	HeaderType HeaderType
+	Checksum   uint32
+}
+
+func (c Container) Alphabet() Alphabet {
+	if c.Flags&FlagByteAlphabet != 0 {
+		return ByteAlphabet
+	}
+	return RuneAlphabet
+}
+
+// hasChecksum reports whether the container itself carries the checksum.
+func (c Container) hasChecksum() bool {
+	return c.Flags&FlagChecksum != 0 && c.Flags&FlagBlocks == 0 &&
+		c.HeaderType != HeaderAdaptive && c.HeaderType != HeaderDictionary
+}
+
+// WriteContainer writes the magic, the current format version, the flags and
+// the header type, followed by the checksum if the container carries it.
+// c.Version is ignored.
+func WriteContainer(w io.Writer, c Container) error {
+	buf := make([]byte, 0, len(Magic)+7)
+	buf = append(buf, Magic[:]...)
+	buf = append(buf, FormatVersion, c.Flags, byte(c.HeaderType))
+	if c.hasChecksum() {
+		buf = binary.LittleEndian.AppendUint32(buf, c.Checksum)
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// ReadContainer reads the container fields from r. Input that does not start
+// with Magic is reported as HeaderLegacy and left unread so the legacy header
+// can be parsed from the same reader.
+func ReadContainer(r *bufio.Reader) (Container, error) {
+	magic, _ := r.Peek(len(Magic))
+	if !bytes.Equal(magic, Magic[:]) {
+		return Container{HeaderType: HeaderLegacy}, nil
+	}
+	r.Discard(len(Magic))
+
+	var fields [3]byte
+	_, err := io.ReadFull(r, fields[:])
+	if err != nil {
+		return Container{}, headerError(err)
+	}
+	c := Container{Version: fields[0], Flags: fields[1], HeaderType: HeaderType(fields[2])}
+	if c.Version == 0 || c.Version > FormatVersion {
+		return Container{}, fmt.Errorf("%w: %d", ErrUnsupportedVersion, c.Version)
+	}
+	if c.Flags&^knownFlags != 0 {
+		return Container{}, fmt.Errorf("%w: flags %#x", ErrUnknownFormat, c.Flags)
+	}
+	if c.HeaderType < HeaderFreq || c.HeaderType > HeaderDictionary {
+		return Container{}, fmt.Errorf("%w: header type %d", ErrUnknownFormat, c.HeaderType)
+	}
+	if c.Flags&FlagBlocks != 0 && c.HeaderType != HeaderCanonical {
+		return Container{}, fmt.Errorf("%w: blocks need canonical headers", ErrUnknownFormat)
+	}
+	if c.Flags&FlagIndex != 0 && c.Flags&FlagBlocks == 0 {
+		return Container{}, fmt.Errorf("%w: an index needs blocks", ErrUnknownFormat)
+	}
+	if c.hasChecksum() {
+		err = binary.Read(r, binary.LittleEndian, &c.Checksum)
+		if err != nil {
+			return Container{}, headerError(err)
+		}
+	}
+	return c, nil
+}
+
+// Decode reads a container, or a legacy file, from r and writes the decoded
+// data to w. If the container carries a checksum, the decoded data is checked
+// against it and ErrChecksumMismatch is returned when they differ.
+func Decode(r io.Reader, w io.Writer) error {
+	_, err := decode(r, w)
+	return err
+}
+
+// Verify decodes r without keeping the output. It returns ErrNoChecksum if r
+// decodes but has no checksum to verify against.
+func Verify(r io.Reader) error {
+	c, err := decode(r, io.Discard)
+	if err != nil {
+		return err
+	}
+	if c.Flags&FlagChecksum == 0 {
+		return ErrNoChecksum
+	}
+	return nil
+}
+
+func decode(r io.Reader, w io.Writer) (Container, error) {
+	br := bufio.NewReader(r)
+	c, err := ReadContainer(br)
+	if err != nil {
+		return c, err
+	}
+	return c, decodeBody(br, w, c, DecoderOptions{})
+}
+
+func decodeBody(br *bufio.Reader, w io.Writer, c Container, opts DecoderOptions) error {
+	if c.Flags&FlagBlocks != 0 {
+		return decodeBlocks(br, w, c, opts)
+	}
+	if c.HeaderType == HeaderAdaptive {
+		return decodeAdaptiveBody(br, w, c, opts)
+	}
+	if c.HeaderType == HeaderDictionary {
+		id, err := readDictionaryID(br)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %08x", ErrNeedDictionary, id)
+	}
+	tree, charCount, err := readTree(br, c, opts)
+	if err != nil {
+		return err
+	}
+	return decodeTreeData(br, w, c, tree, charCount)
+}
+
+// readTree reads the header of a container coded with a single tree.
+func readTree(br *bufio.Reader, c Container, opts DecoderOptions) (Tree, int, error) {
+	switch c.HeaderType {
+	case HeaderLegacy:
+		freqTable, charCount, err := readLegacyHeader(br, opts)
+		if err != nil {
+			return Tree{}, 0, err
+		}
+		return CreateTree(freqTable), charCount, nil
+	case HeaderFreq:
+		freqTable, charCount, err := ReadHeaderWith(br, opts)
+		if err == nil {
+			err = checkSymbols(freqTable, c.Alphabet())
+		}
+		if err != nil {
+			return Tree{}, 0, err
+		}
+		return CreateTree(freqTable), charCount, nil
+	case HeaderCanonical:
+		lengths, charCount, err := ReadCanonicalHeaderWith(br, opts)
+		if err == nil {
+			err = checkSymbols(lengths, c.Alphabet())
+		}
+		if err != nil {
+			return Tree{}, 0, err
+		}
+		tree, err := CreateCanonicalTree(lengths)
+		return tree, charCount, err
+	}
+	return Tree{}, 0, fmt.Errorf("%w: header type %d", ErrUnknownFormat, c.HeaderType)
+}
+
+func decodeTreeData(br *bufio.Reader, w io.Writer, c Container, tree Tree, charCount int) error {
+	if !c.hasChecksum() {
+		return DecodeAndWriteDataWith(br, w, tree, charCount, c.Alphabet())
+	}
+	crc := crc32.NewIEEE()
+	err := DecodeAndWriteDataWith(br, io.MultiWriter(w, crc), tree, charCount, c.Alphabet())
+	if err != nil {
+		return err
+	}
+	if crc.Sum32() != c.Checksum {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
+// readLegacyHeader parses a header without a container. Since there is no
+// magic to go by, the header is only accepted if it is fully consistent.
+func readLegacyHeader(r *bufio.Reader, opts DecoderOptions) (FreqTable, int, error) {
+	counts, err := r.Peek(8)
+	if err != nil {
+		return nil, 0, ErrUnknownFormat
+	}
+	entryCount := int32(binary.LittleEndian.Uint32(counts[:4]))
+	charCount := int32(binary.LittleEndian.Uint32(counts[4:]))
+	if entryCount <= 0 || entryCount > utf8.MaxRune+1 || charCount < entryCount {
+		return nil, 0, ErrUnknownFormat
+	}
+
+	freqTable, count, err := ReadHeaderWith(r, opts)
+	if errors.Is(err, ErrLimitExceeded) {
+		return nil, 0, err
+	}
+	if err != nil {
+		return nil, 0, ErrUnknownFormat
+	}
+	return freqTable, count, nil
+}
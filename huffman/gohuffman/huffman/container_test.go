@@ -0,0 +1,184 @@
+package huffman
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const containerTestText = "hello world! this is a test message with various characters 123"
+
+func encodeLegacy(t testing.TB, text string) []byte {
+	freqTable := freqOf(t, strings.NewReader(text), RuneAlphabet)
+	var buf bytes.Buffer
+	assert.NoError(t, WriteHeader(&buf, freqTable))
+	assert.NoError(t, WriteData(strings.NewReader(text), &buf, CreateTree(freqTable).BuildEncodingMap()))
+	return buf.Bytes()
+}
+
+func encodeContainer(t testing.TB, text string, c Container) []byte {
+	freqTable := freqOf(t, strings.NewReader(text), c.Alphabet())
+	tree := CreateTree(freqTable)
+	if c.Flags&FlagChecksum != 0 {
+		c.Checksum = crc32.ChecksumIEEE([]byte(text))
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteContainer(&buf, c))
+	encodedMap := tree.BuildEncodingMap()
+	if c.HeaderType == HeaderCanonical {
+		lengths := tree.CodeLengths()
+		encodedMap = lengths.BuildEncodingMap()
+		assert.NoError(t, WriteCanonicalHeader(&buf, lengths, freqTable.CharCount()))
+	} else {
+		assert.NoError(t, WriteHeader(&buf, freqTable))
+	}
+	assert.NoError(t, WriteDataWith(strings.NewReader(text), &buf, encodedMap, c.Alphabet()))
+	return buf.Bytes()
+}
+
+func TestContainerRoundtrip(t *testing.T) {
+	var buf bytes.Buffer
+	written := Container{HeaderType: HeaderCanonical, Flags: FlagChecksum, Checksum: 0xdeadbeef}
+	err := WriteContainer(&buf, written)
+	assert.NoError(t, err)
+	assert.Equal(t, Magic[:], buf.Bytes()[:len(Magic)])
+
+	c, err := ReadContainer(bufio.NewReader(&buf))
+	assert.NoError(t, err)
+	written.Version = FormatVersion
+	assert.Equal(t, written, c)
+}
+
+func TestDecodeContainer(t *testing.T) {
+	containers := []Container{
+		{HeaderType: HeaderFreq},
+		{HeaderType: HeaderCanonical},
+		{HeaderType: HeaderFreq, Flags: FlagChecksum},
+		{HeaderType: HeaderCanonical, Flags: FlagChecksum},
+		{HeaderType: HeaderCanonical, Flags: FlagChecksum | FlagByteAlphabet},
+	}
+	for _, c := range containers {
+		var decoded bytes.Buffer
+		err := Decode(bytes.NewReader(encodeContainer(t, containerTestText, c)), &decoded)
+		assert.NoError(t, err)
+		assert.Equal(t, containerTestText, decoded.String())
+	}
+}
+
+func TestDecodeBinaryData(t *testing.T) {
+	binaryText := containerTestText + "\xff\xfe\x00\xc3"
+
+	var decoded bytes.Buffer
+	encoded := encodeContainer(t, binaryText, Container{HeaderType: HeaderCanonical, Flags: FlagChecksum | FlagByteAlphabet})
+	err := Decode(bytes.NewReader(encoded), &decoded)
+	assert.NoError(t, err)
+	assert.Equal(t, binaryText, decoded.String())
+}
+
+func TestDecodeLegacyLayout(t *testing.T) {
+	// Files written by the encoder from before the container existed.
+	files := map[string][]byte{
+		"abracadabra": {
+			0x05, 0x00, 0x00, 0x00, 0x0b, 0x00, 0x00, 0x00, 0x72, 0x00, 0x00, 0x00,
+			0x02, 0x00, 0x00, 0x00, 0x63, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00,
+			0x64, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x61, 0x00, 0x00, 0x00,
+			0x05, 0x00, 0x00, 0x00, 0x62, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00,
+			0x69, 0xcf, 0x68,
+		},
+		"aaaaaaaaaa": {
+			0x01, 0x00, 0x00, 0x00, 0x0a, 0x00, 0x00, 0x00, 0x61, 0x00, 0x00, 0x00,
+			0x0a, 0x00, 0x00, 0x00,
+		},
+	}
+	for text, file := range files {
+		t.Run(text, func(t *testing.T) {
+			var decoded bytes.Buffer
+			assert.NoError(t, Decode(bytes.NewReader(file), &decoded))
+			assert.Equal(t, text, decoded.String())
+		})
+	}
+}
+
+func TestDecodeLoneSymbolContainer(t *testing.T) {
+	// A freq header for a lone symbol is followed by no data at all.
+	encoded := []byte{
+		0x47, 0x48, 0x55, 0x46, 0x01, 0x01, 0x01, 0xf0, 0xcd, 0x11, 0x4c, 0x01,
+		0x00, 0x00, 0x00, 0x0a, 0x00, 0x00, 0x00, 0x61, 0x00, 0x00, 0x00, 0x0a,
+		0x00, 0x00, 0x00,
+	}
+	var decoded bytes.Buffer
+	assert.NoError(t, Decode(bytes.NewReader(encoded), &decoded))
+	assert.Equal(t, "aaaaaaaaaa", decoded.String())
+}
+
+func TestDecodeRejectsForeignInput(t *testing.T) {
+	inputs := map[string][]byte{
+		"empty":     {},
+		"text":      []byte(containerTestText),
+		"gzip":      {0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03},
+		"bad count": {0xff, 0xff, 0xff, 0xff, 0x01, 0x00, 0x00, 0x00},
+	}
+	for name, input := range inputs {
+		t.Run(name, func(t *testing.T) {
+			err := Decode(bytes.NewReader(input), &bytes.Buffer{})
+			assert.ErrorIs(t, err, ErrUnknownFormat)
+		})
+	}
+}
+
+func TestReadContainerRejectsUnknownFields(t *testing.T) {
+	header := append(Magic[:0:0], Magic[:]...)
+
+	_, err := ReadContainer(bufio.NewReader(bytes.NewReader(append(header, FormatVersion+1, 0, byte(HeaderFreq)))))
+	assert.ErrorIs(t, err, ErrUnsupportedVersion)
+
+	_, err = ReadContainer(bufio.NewReader(bytes.NewReader(append(header, FormatVersion, 0, 0x7f))))
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+
+	_, err = ReadContainer(bufio.NewReader(bytes.NewReader(append(header, FormatVersion, 0x80, byte(HeaderFreq)))))
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
+
+func TestDecodeDetectsCorruption(t *testing.T) {
+	encoded := encodeContainer(t, containerTestText, Container{HeaderType: HeaderCanonical, Flags: FlagChecksum})
+	lengths := CreateTree(freqOf(t, strings.NewReader(containerTestText), RuneAlphabet)).CodeLengths()
+	var data bytes.Buffer
+	assert.NoError(t, WriteData(strings.NewReader(containerTestText), &data, lengths.BuildEncodingMap()))
+	dataStart := len(encoded) - data.Len()
+
+	// Every bit of the data except the padding in the last byte matters.
+	mismatches := 0
+	for i := dataStart; i < len(encoded)-1; i++ {
+		for bit := 0; bit < 8; bit++ {
+			corrupted := bytes.Clone(encoded)
+			corrupted[i] ^= 1 << bit
+			err := Decode(bytes.NewReader(corrupted), io.Discard)
+			assert.Error(t, err)
+			if errors.Is(err, ErrChecksumMismatch) {
+				mismatches++
+			}
+		}
+	}
+	assert.Positive(t, mismatches)
+
+	err := Decode(bytes.NewReader(encoded[:len(encoded)-5]), io.Discard)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestVerify(t *testing.T) {
+	encoded := encodeContainer(t, containerTestText, Container{HeaderType: HeaderFreq, Flags: FlagChecksum})
+	assert.NoError(t, Verify(bytes.NewReader(encoded)))
+
+	encoded[len(Magic)+3] ^= 0x01
+	assert.ErrorIs(t, Verify(bytes.NewReader(encoded)), ErrChecksumMismatch)
+
+	encoded = encodeContainer(t, containerTestText, Container{HeaderType: HeaderFreq})
+	assert.ErrorIs(t, Verify(bytes.NewReader(encoded)), ErrNoChecksum)
+}
@@ -0,0 +1,183 @@
+package huffman
+
+import (
+	"bufio"
+	"io"
+)
+
+// tableBits is how many bits the primary lookup table resolves at once.
+// Longer codes continue in subtables of at most tableBits each.
+const tableBits = 10
+
+type code struct {
+	symbol rune
+	bits   uint64
+	length int
+}
+
+// codes lists every symbol with its code as an integer, the code's first bit
+// being the most significant one. ok is false if a code is too long for the
+// integer.
+func (t Tree) codes() (codes []code, ok bool) {
+	ok = true
+	var walk func(node BaseNode, bits uint64, length int)
+	walk = func(node BaseNode, bits uint64, length int) {
+		if node.IsLeaf() {
+			codes = append(codes, code{node.(*LeafNode).Element, bits, length})
+			return
+		}
+		if length == 64 {
+			ok = false
+			return
+		}
+		internal := node.(*InternalNode)
+		walk(internal.Left, bits<<1, length+1)
+		walk(internal.Right, bits<<1|1, length+1)
+	}
+	if t.Root != nil {
+		walk(t.Root, 0, 0)
+	}
+	return codes, ok
+}
+
+type tableEntry struct {
+	symbol rune
+	// length is the number of bits the entry resolves. It is zero for
+	// entries that no code maps to.
+	length int
+	sub    *decodeTable
+}
+
+// decodeTable resolves the next bits of input in one lookup. Codes longer
+// than bits continue in a subtable indexed by the bits that follow.
+type decodeTable struct {
+	bits    int
+	entries []tableEntry
+}
+
+func newDecodeTable(codes []code, bits int) *decodeTable {
+	t := &decodeTable{bits: bits, entries: make([]tableEntry, 1<<bits)}
+	groups := map[uint64][]code{}
+	for _, c := range codes {
+		if c.length <= bits {
+			shift := bits - c.length
+			start := c.bits << shift
+			for i := start; i < start+1<<shift; i++ {
+				t.entries[i] = tableEntry{symbol: c.symbol, length: c.length}
+			}
+			continue
+		}
+		rest := c.length - bits
+		prefix := c.bits >> rest
+		groups[prefix] = append(groups[prefix], code{c.symbol, c.bits & (1<<rest - 1), rest})
+	}
+	for prefix, group := range groups {
+		maxLen := 0
+		for _, c := range group {
+			maxLen = max(maxLen, c.length)
+		}
+		t.entries[prefix] = tableEntry{length: bits, sub: newDecodeTable(group, min(maxLen, tableBits))}
+	}
+	return t
+}
+
+// bitBuffer feeds the table decoder. It keeps up to 64 unread bits in acc,
+// the oldest bit being the most significant of the n valid ones.
+type bitBuffer struct {
+	r   io.ByteReader
+	acc uint64
+	n   int
+	err error
+}
+
+// peek returns the next k bits, padding with zeros past the end of input.
+func (b *bitBuffer) peek(k int) uint64 {
+	for b.n < k && b.err == nil {
+		for b.n <= 56 {
+			c, err := b.r.ReadByte()
+			if err != nil {
+				b.err = err
+				break
+			}
+			b.acc = b.acc<<8 | uint64(c)
+			b.n += 8
+		}
+	}
+	if b.n < k {
+		return b.acc << (k - b.n) & (1<<k - 1)
+	}
+	return b.acc >> (b.n - k) & (1<<k - 1)
+}
+
+// consume drops k bits that were returned by peek.
+func (b *bitBuffer) consume(k int) error {
+	if k > b.n {
+		return unexpectedEOF(b.err)
+	}
+	b.n -= k
+	b.acc &= 1<<b.n - 1
+	return nil
+}
+
+func decodeTableDriven(reader io.Reader, writer io.Writer, tree Tree, charCount int, alphabet Alphabet) error {
+	codes, ok := tree.codes()
+	if !ok {
+		return decodeTreeWalk(reader, writer, tree, charCount, alphabet)
+	}
+	w := bufio.NewWriter(writer)
+	out := make([]byte, 0, 4096)
+
+	// A lone symbol takes no bits.
+	if len(codes) == 1 {
+		symbol := alphabet.appendSymbol(nil, codes[0].symbol)
+		for i := 0; i < charCount; i++ {
+			_, err := w.Write(symbol)
+			if err != nil {
+				return err
+			}
+		}
+		return w.Flush()
+	}
+
+	maxLen := 0
+	for _, c := range codes {
+		maxLen = max(maxLen, c.length)
+	}
+	root := newDecodeTable(codes, min(maxLen, tableBits))
+	br, ok := reader.(io.ByteReader)
+	if !ok {
+		br = bufio.NewReader(reader)
+	}
+	bb := &bitBuffer{r: br}
+
+	for decoded := 0; decoded < charCount; decoded++ {
+		t := root
+		for {
+			entry := t.entries[bb.peek(t.bits)]
+			if entry.length == 0 {
+				return errInvalidCode
+			}
+			err := bb.consume(entry.length)
+			if err != nil {
+				return err
+			}
+			if entry.sub == nil {
+				out = alphabet.appendSymbol(out, entry.symbol)
+				break
+			}
+			t = entry.sub
+		}
+		if len(out) >= cap(out)-4 {
+			_, err := w.Write(out)
+			if err != nil {
+				return err
+			}
+			out = out[:0]
+		}
+	}
+	_, err := w.Write(out)
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
@@ -0,0 +1,322 @@
+package huffman
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math/bits"
+	"unicode/utf8"
+
+	"github.com/Ninad-Bhangui/gohuffman/bitwriter"
+)
+
+// DictionaryMagic starts every dictionary file.
+var DictionaryMagic = [4]byte{'G', 'H', 'D', 'I'}
+
+// Dictionary is a code trained ahead of time, so small inputs can be coded
+// without a header of their own. Symbols missing from the training data are
+// sent after the code of an escape symbol.
+type Dictionary struct {
+	// ID is the CRC-32 of the alphabet and code lengths, so the same training
+	// data always gives the same ID.
+	ID       uint32
+	Alphabet Alphabet
+	// Lengths holds the code of every trained symbol and of the escape
+	// symbol.
+	Lengths CodeLengths
+	// Trained is how many symbols the dictionary was trained on.
+	Trained int
+}
+
+// escapeSymbol is one past the largest symbol of the alphabet, so it never
+// collides with real data.
+func (a Alphabet) escapeSymbol() rune {
+	if a == ByteAlphabet {
+		return 256
+	}
+	return utf8.MaxRune + 1
+}
+
+// TrainDictionary builds a dictionary from the symbol frequencies of every
+// reader in corpus.
+func TrainDictionary(alphabet Alphabet, corpus ...io.Reader) (*Dictionary, error) {
+	freqTable := FreqTable{}
+	for _, r := range corpus {
+		docTable, err := CalculateFreqWith(r, alphabet)
+		if err != nil {
+			return nil, err
+		}
+		for symbol, freq := range docTable {
+			freqTable[symbol] += freq
+		}
+	}
+	return NewDictionary(freqTable, alphabet)
+}
+
+// NewDictionary builds a dictionary from the frequencies of training data.
+func NewDictionary(freqTable FreqTable, alphabet Alphabet) (*Dictionary, error) {
+	if len(freqTable) == 0 {
+		return nil, fmt.Errorf("%w: no training data", ErrBadDictionary)
+	}
+	withEscape := FreqTable{alphabet.escapeSymbol(): 1}
+	for symbol, freq := range freqTable {
+		withEscape[symbol] = freq
+	}
+	lengths, err := codeLengths(withEscape, 0)
+	if err != nil {
+		return nil, err
+	}
+	return dictionaryFromLengths(alphabet, lengths, freqTable.CharCount())
+}
+
+func dictionaryFromLengths(alphabet Alphabet, lengths CodeLengths, trained int) (*Dictionary, error) {
+	d := &Dictionary{Alphabet: alphabet, Lengths: lengths, Trained: trained}
+	if _, ok := lengths[alphabet.escapeSymbol()]; !ok || len(lengths) < 2 {
+		return nil, ErrBadDictionary
+	}
+	for symbol := range lengths {
+		if symbol < 0 || symbol > alphabet.escapeSymbol() {
+			return nil, ErrBadDictionary
+		}
+	}
+	if lengths.validate() != nil {
+		return nil, ErrBadDictionary
+	}
+	d.ID = crc32.ChecksumIEEE(d.appendCode(nil))
+	return d, nil
+}
+
+// appendCode appends the alphabet and the code lengths as WriteDictionary
+// stores them.
+func (d *Dictionary) appendCode(buf []byte) []byte {
+	var code bytes.Buffer
+	code.WriteByte(byte(d.Alphabet))
+	WriteCanonicalHeader(&code, d.Lengths, 0)
+	return append(buf, code.Bytes()...)
+}
+
+// WriteDictionary writes DictionaryMagic, the format version, the ID, the
+// alphabet and the code lengths as a canonical header whose symbol count is
+// the number of trained symbols.
+func WriteDictionary(w io.Writer, d *Dictionary) error {
+	buf := append(DictionaryMagic[:0:0], DictionaryMagic[:]...)
+	buf = append(buf, FormatVersion)
+	buf = binary.LittleEndian.AppendUint32(buf, d.ID)
+	buf = append(buf, byte(d.Alphabet))
+	_, err := w.Write(buf)
+	if err != nil {
+		return err
+	}
+	return WriteCanonicalHeader(w, d.Lengths, d.Trained)
+}
+
+func ReadDictionary(r io.Reader) (*Dictionary, error) {
+	var fields [len(DictionaryMagic) + 6]byte
+	_, err := io.ReadFull(r, fields[:])
+	if err != nil || !bytes.Equal(fields[:len(DictionaryMagic)], DictionaryMagic[:]) {
+		return nil, ErrBadDictionary
+	}
+	version := fields[len(DictionaryMagic)]
+	if version == 0 || version > FormatVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+	id := binary.LittleEndian.Uint32(fields[len(DictionaryMagic)+1:])
+	alphabet := Alphabet(fields[len(fields)-1])
+	if alphabet != RuneAlphabet && alphabet != ByteAlphabet {
+		return nil, ErrBadDictionary
+	}
+	lengths, trained, err := ReadCanonicalHeader(r)
+	if err != nil {
+		return nil, ErrBadDictionary
+	}
+	d, err := dictionaryFromLengths(alphabet, lengths, trained)
+	if err != nil {
+		return nil, err
+	}
+	if d.ID != id {
+		return nil, fmt.Errorf("%w: ID does not match its contents", ErrBadDictionary)
+	}
+	return d, nil
+}
+
+// EncodeDictionary writes a container that refers to d by its ID instead of
+// holding a header. A symbol d does not know is sent as the escape code, a
+// zero bit and the raw symbol; the escape code followed by a one bit ends the
+// data. The CRC-32 of r follows, as for EncodeAdaptive.
+func EncodeDictionary(r io.Reader, w io.Writer, d *Dictionary) error {
+	c := Container{HeaderType: HeaderDictionary, Flags: FlagChecksum}
+	if d.Alphabet == ByteAlphabet {
+		c.Flags |= FlagByteAlphabet
+	}
+	err := WriteContainer(w, c)
+	if err != nil {
+		return err
+	}
+	err = binary.Write(w, binary.LittleEndian, d.ID)
+	if err != nil {
+		return err
+	}
+
+	crc := crc32.NewIEEE()
+	bw := bitwriter.NewBitWriter(w)
+	codes := packCodes(d.Lengths.BuildEncodingMap())
+	escape := codes[d.Alphabet.escapeSymbol()]
+	scanner := bufio.NewScanner(io.TeeReader(r, crc))
+	scanner.Split(d.Alphabet.splitFunc())
+	for scanner.Scan() {
+		symbol := d.Alphabet.symbol(scanner.Bytes())
+		if code, ok := codes[symbol]; ok {
+			err = code.write(&bw)
+		} else {
+			err = writeEscaped(&bw, escape, symbol, d.Alphabet)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	err = scanner.Err()
+	if err != nil {
+		return err
+	}
+	err = escape.write(&bw)
+	if err == nil {
+		err = bw.WriteBit(true)
+	}
+	if err != nil {
+		return err
+	}
+	err = bw.Flush()
+	if err != nil {
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, crc.Sum32())
+}
+
+func writeEscaped(bw *bitwriter.BitWriter, escape packedCode, symbol rune, alphabet Alphabet) error {
+	err := escape.write(bw)
+	if err != nil {
+		return err
+	}
+	err = bw.WriteBit(false)
+	if err != nil {
+		return err
+	}
+	return bw.WriteBits(uint64(symbol), alphabet.symbolBits())
+}
+
+func readDictionaryID(r io.Reader) (uint32, error) {
+	var id uint32
+	err := binary.Read(r, binary.LittleEndian, &id)
+	return id, unexpectedEOF(err)
+}
+
+// dictionaryFor checks that d is the dictionary c was coded with.
+func dictionaryFor(c Container, id uint32, d *Dictionary) error {
+	if d == nil || d.ID != id {
+		return fmt.Errorf("%w: %08x", ErrNeedDictionary, id)
+	}
+	if d.Alphabet != c.Alphabet() {
+		return fmt.Errorf("%w: alphabet does not match", ErrBadDictionary)
+	}
+	return nil
+}
+
+// decodeDictionaryBody decodes what follows the dictionary ID. Like adaptive
+// data, it announces no symbol count, so opts.MaxCharCount is enforced on the
+// output.
+func decodeDictionaryBody(r io.Reader, writer io.Writer, c Container, d *Dictionary, opts DecoderOptions) error {
+	tree, err := CreateCanonicalTree(d.Lengths)
+	if err != nil {
+		return err
+	}
+	codes, _ := tree.codes()
+	maxLen := 0
+	for _, k := range codes {
+		maxLen = max(maxLen, k.length)
+	}
+	table := newDecodeTable(codes, min(maxLen, tableBits))
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	bb := &bitBuffer{r: br}
+	escape := d.Alphabet.escapeSymbol()
+	symbolBits := d.Alphabet.symbolBits()
+
+	crc := crc32.NewIEEE()
+	w := bufio.NewWriter(io.MultiWriter(writer, crc))
+	var out []byte
+	for decoded := 0; ; decoded++ {
+		t := table
+		var symbol rune
+		for {
+			entry := t.entries[bb.peek(t.bits)]
+			if entry.length == 0 {
+				return errInvalidCode
+			}
+			err := bb.consume(entry.length)
+			if err != nil {
+				return err
+			}
+			if entry.sub == nil {
+				symbol = entry.symbol
+				break
+			}
+			t = entry.sub
+		}
+
+		if symbol == escape {
+			end := bb.peek(1)
+			err := bb.consume(1)
+			if err != nil {
+				return err
+			}
+			if end == 1 {
+				break
+			}
+			symbol = rune(bb.peek(symbolBits))
+			err = bb.consume(symbolBits)
+			if err != nil {
+				return err
+			}
+			if !d.Alphabet.validSymbol(symbol) {
+				return errInvalidCode
+			}
+		}
+		if opts.MaxCharCount > 0 && decoded == opts.MaxCharCount {
+			return fmt.Errorf("%w: more than %d symbols decoded", ErrLimitExceeded, decoded)
+		}
+		out = d.Alphabet.appendSymbol(out[:0], symbol)
+		_, err := w.Write(out)
+		if err != nil {
+			return err
+		}
+	}
+	err = w.Flush()
+	if err != nil {
+		return err
+	}
+	if c.Flags&FlagChecksum == 0 {
+		return nil
+	}
+
+	// The checksum starts at the next byte.
+	err = bb.consume(bb.n % 8)
+	if err != nil {
+		return err
+	}
+	checksum := bb.peek(32)
+	err = bb.consume(32)
+	if err != nil {
+		return err
+	}
+	// The checksum is little endian while bb reads bytes most significant
+	// first.
+	if bits.ReverseBytes32(uint32(checksum)) != crc.Sum32() {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
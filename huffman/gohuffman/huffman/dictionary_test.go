@@ -0,0 +1,116 @@
+package huffman
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var dictionaryCorpus = []string{
+	`{"id": 1, "user": "alice", "event": "login", "ok": true}`,
+	`{"id": 2, "user": "bob", "event": "logout", "ok": true}`,
+	`{"id": 3, "user": "carol", "event": "login", "ok": false}`,
+}
+
+func trainTestDictionary(t *testing.T, alphabet Alphabet) *Dictionary {
+	var corpus []io.Reader
+	for _, doc := range dictionaryCorpus {
+		corpus = append(corpus, strings.NewReader(doc))
+	}
+	d, err := TrainDictionary(alphabet, corpus...)
+	assert.NoError(t, err)
+	return d
+}
+
+func decodeWithDictionary(t *testing.T, encoded []byte, d *Dictionary) (string, error) {
+	zr, err := NewReaderOptions(bytes.NewReader(encoded), ReaderOptions{Dictionary: d})
+	if err != nil {
+		return "", err
+	}
+	decoded, err := io.ReadAll(zr)
+	return string(decoded), err
+}
+
+func TestDictionaryRoundtrip(t *testing.T) {
+	messages := []string{
+		"",
+		`{"id": 4, "user": "dave", "event": "login", "ok": true}`,
+		// Symbols the corpus never had go through the escape code.
+		`{"id": 5, "user": "zoë", "event": "quit", "ok": null}`,
+	}
+	for _, alphabet := range []Alphabet{RuneAlphabet, ByteAlphabet} {
+		d := trainTestDictionary(t, alphabet)
+		for _, message := range messages {
+			var encoded bytes.Buffer
+			assert.NoError(t, EncodeDictionary(strings.NewReader(message), &encoded, d))
+			decoded, err := decodeWithDictionary(t, encoded.Bytes(), d)
+			assert.NoError(t, err)
+			assert.Equal(t, message, decoded, "%s alphabet", alphabet)
+		}
+	}
+}
+
+func TestDictionarySavesHeader(t *testing.T) {
+	d := trainTestDictionary(t, RuneAlphabet)
+	message := `{"id": 4, "user": "dave", "event": "login", "ok": true}`
+
+	var withDictionary bytes.Buffer
+	assert.NoError(t, EncodeDictionary(strings.NewReader(message), &withDictionary, d))
+	withHeader := encodeContainer(t, message, Container{HeaderType: HeaderCanonical, Flags: FlagChecksum})
+	assert.Less(t, withDictionary.Len(), len(withHeader))
+}
+
+func TestDictionaryFile(t *testing.T) {
+	d := trainTestDictionary(t, RuneAlphabet)
+	assert.Equal(t, d.ID, trainTestDictionary(t, RuneAlphabet).ID)
+	assert.NotEqual(t, d.ID, trainTestDictionary(t, ByteAlphabet).ID)
+
+	var file bytes.Buffer
+	assert.NoError(t, WriteDictionary(&file, d))
+	read, err := ReadDictionary(bytes.NewReader(file.Bytes()))
+	assert.NoError(t, err)
+	assert.Equal(t, d, read)
+
+	data := file.Bytes()
+	for i := range data {
+		corrupted := bytes.Clone(data)
+		corrupted[i] ^= 0x01
+		read, err := ReadDictionary(bytes.NewReader(corrupted))
+		if err == nil {
+			// Only the trained symbol count is not covered by the ID.
+			assert.Equal(t, d.Lengths, read.Lengths, "byte %d", i)
+			assert.NotEqual(t, d.Trained, read.Trained, "byte %d", i)
+		}
+	}
+	_, err = ReadDictionary(bytes.NewReader(data[:len(data)-1]))
+	assert.ErrorIs(t, err, ErrBadDictionary)
+
+	_, err = TrainDictionary(RuneAlphabet, strings.NewReader(""))
+	assert.ErrorIs(t, err, ErrBadDictionary)
+}
+
+func TestDictionaryMustMatch(t *testing.T) {
+	d := trainTestDictionary(t, RuneAlphabet)
+	other, err := TrainDictionary(RuneAlphabet, strings.NewReader("something else entirely"))
+	assert.NoError(t, err)
+
+	var encoded bytes.Buffer
+	assert.NoError(t, EncodeDictionary(strings.NewReader(dictionaryCorpus[0]), &encoded, d))
+
+	_, err = decodeWithDictionary(t, encoded.Bytes(), nil)
+	assert.ErrorIs(t, err, ErrNeedDictionary)
+	_, err = decodeWithDictionary(t, encoded.Bytes(), other)
+	assert.ErrorIs(t, err, ErrNeedDictionary)
+	assert.ErrorIs(t, Decode(bytes.NewReader(encoded.Bytes()), io.Discard), ErrNeedDictionary)
+
+	corrupted := bytes.Clone(encoded.Bytes())
+	corrupted[len(corrupted)-1] ^= 0x80
+	_, err = decodeWithDictionary(t, corrupted, d)
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+
+	_, err = decodeWithDictionary(t, encoded.Bytes()[:encoded.Len()-5], d)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
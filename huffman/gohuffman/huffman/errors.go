@@ -0,0 +1,42 @@
+package huffman
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Errors reported while reading compressed data, and ErrInvalidUTF8 and
+// ErrInputTooLarge while writing it. Most are wrapped with details, so check for them with
+// errors.Is.
+var (
+	ErrUnknownFormat      = errors.New("huffman: unknown or foreign file format")
+	ErrUnsupportedVersion = errors.New("huffman: unsupported format version")
+	// ErrCorruptHeader means a header cannot be parsed or does not describe
+	// a valid code.
+	ErrCorruptHeader = errors.New("huffman: corrupt header")
+	// ErrCorruptData means the coded data contains a code that maps to no
+	// symbol.
+	ErrCorruptData = errors.New("huffman: corrupt data")
+	// ErrTruncatedData means the input ended in the middle of the data. It
+	// also matches io.ErrUnexpectedEOF.
+	ErrTruncatedData    = fmt.Errorf("huffman: data is truncated: %w", io.ErrUnexpectedEOF)
+	ErrChecksumMismatch = errors.New("huffman: checksum mismatch, data is corrupt")
+	ErrNoChecksum       = errors.New("huffman: no checksum stored")
+	ErrNeedDictionary   = errors.New("huffman: data was coded with a dictionary that was not given")
+	ErrBadDictionary    = errors.New("huffman: invalid dictionary")
+	ErrNoIndex          = errors.New("huffman: no block index")
+	// ErrLimitExceeded means a header asks for more than DecoderOptions
+	// allow.
+	ErrLimitExceeded = errors.New("huffman: header exceeds decoder limits")
+
+	// ErrInvalidUTF8 is returned when coding input that is not UTF-8 with
+	// the rune alphabet, which could not reproduce it.
+	ErrInvalidUTF8 = errors.New("huffman: input is not valid UTF-8, use the byte alphabet")
+	// ErrInputTooLarge is returned when a header cannot hold the symbol
+	// count of the input. Block containers can, see EncodeStream.
+	ErrInputTooLarge = errors.New("huffman: too many symbols for a single header, use the block format")
+
+	ErrInvalidCodeLengths = fmt.Errorf("%w: invalid code lengths", ErrCorruptHeader)
+	errInvalidCode        = fmt.Errorf("%w: invalid code", ErrCorruptData)
+)
@@ -0,0 +1,129 @@
+package huffman
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errBoom = errors.New("boom")
+
+// failingWriter accepts n bytes and then fails.
+type failingWriter struct {
+	n int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		written := w.n
+		w.n = 0
+		return written, errBoom
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+// failingReader returns data and then fails instead of reporting io.EOF.
+func failingReader(data []byte) io.Reader {
+	return io.MultiReader(bytes.NewReader(data), iotest.ErrReader(errBoom))
+}
+
+func everyFormat(t testing.TB, text string) map[string][]byte {
+	var adaptive, blocks bytes.Buffer
+	assert.NoError(t, EncodeAdaptive(strings.NewReader(text), &adaptive, RuneAlphabet))
+	assert.NoError(t, EncodeStream(strings.NewReader(text), &blocks, WriterOptions{BlockSize: 16}))
+	return map[string][]byte{
+		"freq":      encodeContainer(t, text, Container{HeaderType: HeaderFreq, Flags: FlagChecksum}),
+		"canonical": encodeContainer(t, text, Container{HeaderType: HeaderCanonical, Flags: FlagChecksum}),
+		"adaptive":  adaptive.Bytes(),
+		"blocks":    blocks.Bytes(),
+	}
+}
+
+func TestEncodersReportReadErrors(t *testing.T) {
+	_, err := CalculateFreq(failingReader([]byte("some text")))
+	assert.ErrorIs(t, err, errBoom)
+
+	encodedMap := map[rune]string{'a': "0", 'b': "1"}
+	assert.ErrorIs(t, WriteDataWith(failingReader([]byte("ab")), io.Discard, encodedMap, RuneAlphabet), errBoom)
+	assert.ErrorIs(t, EncodeAdaptive(failingReader([]byte("ab")), io.Discard, RuneAlphabet), errBoom)
+	assert.ErrorIs(t, EncodeStream(failingReader([]byte("ab")), io.Discard, WriterOptions{}), errBoom)
+
+	d := trainTestDictionary(t, RuneAlphabet)
+	assert.ErrorIs(t, EncodeDictionary(failingReader([]byte("ab")), io.Discard, d), errBoom)
+	_, err = TrainDictionary(RuneAlphabet, failingReader([]byte("ab")))
+	assert.ErrorIs(t, err, errBoom)
+}
+
+func TestEncodersReportWriteErrors(t *testing.T) {
+	text := strings.Repeat(containerTestText, 3)
+	freqTable := freqOf(t, strings.NewReader(text), RuneAlphabet)
+	encodedMap := CreateTree(freqTable).BuildEncodingMap()
+
+	for n := 0; n < 40; n += 7 {
+		assert.ErrorIs(t, WriteHeader(&failingWriter{n: n}, freqTable), errBoom)
+		assert.ErrorIs(t, WriteDataWith(strings.NewReader(text), &failingWriter{n: n}, encodedMap, RuneAlphabet), errBoom)
+		assert.ErrorIs(t, EncodeAdaptive(strings.NewReader(text), &failingWriter{n: n}, RuneAlphabet), errBoom)
+		assert.ErrorIs(t, EncodeStream(strings.NewReader(text), &failingWriter{n: n}, WriterOptions{BlockSize: 16}), errBoom)
+		assert.ErrorIs(t, EncodeDictionary(strings.NewReader(text), &failingWriter{n: n}, trainTestDictionary(t, RuneAlphabet)), errBoom)
+	}
+}
+
+func TestDecodersReportWriteErrors(t *testing.T) {
+	text := strings.Repeat(containerTestText, 3)
+	for name, encoded := range everyFormat(t, text) {
+		for _, n := range []int{0, 10, len(text) - 1} {
+			err := Decode(bytes.NewReader(encoded), &failingWriter{n: n})
+			assert.ErrorIs(t, err, errBoom, "%s, fail after %d bytes", name, n)
+		}
+	}
+
+	freqTable := freqOf(t, strings.NewReader(text), RuneAlphabet)
+	tree := CreateTree(freqTable)
+	var encoded bytes.Buffer
+	assert.NoError(t, WriteData(strings.NewReader(text), &encoded, tree.BuildEncodingMap()))
+	for _, decode := range []func(io.Reader, io.Writer, Tree, int, Alphabet) error{decodeTableDriven, decodeTreeWalk} {
+		err := decode(bytes.NewReader(encoded.Bytes()), &failingWriter{n: 5}, tree, freqTable.CharCount(), RuneAlphabet)
+		assert.ErrorIs(t, err, errBoom)
+	}
+}
+
+func TestDecodersReportReadErrors(t *testing.T) {
+	for name, encoded := range everyFormat(t, strings.Repeat(containerTestText, 3)) {
+		for _, n := range []int{len(Magic) + 3, len(encoded) / 2, len(encoded) - 1} {
+			err := Decode(failingReader(encoded[:n]), io.Discard)
+			assert.ErrorIs(t, err, errBoom, "%s, fail after %d bytes", name, n)
+		}
+	}
+}
+
+func TestDecodersReportTruncatedData(t *testing.T) {
+	for name, encoded := range everyFormat(t, strings.Repeat(containerTestText, 3)) {
+		for n := len(Magic); n < len(encoded); n++ {
+			err := Decode(bytes.NewReader(encoded[:n]), io.Discard)
+			assert.ErrorIs(t, err, ErrTruncatedData, "%s, cut at %d bytes", name, n)
+			assert.ErrorIs(t, err, io.ErrUnexpectedEOF, "%s, cut at %d bytes", name, n)
+		}
+	}
+
+	// The header is cut short too.
+	encoded := encodeContainer(t, containerTestText, Container{HeaderType: HeaderCanonical})
+	err := Decode(bytes.NewReader(encoded[:len(Magic)+5]), io.Discard)
+	assert.ErrorIs(t, err, ErrCorruptHeader)
+	assert.ErrorIs(t, err, ErrTruncatedData)
+}
+
+func TestCorruptHeaderErrors(t *testing.T) {
+	lengths := CodeLengths{'a': 1, 'b': 2}
+	var header bytes.Buffer
+	assert.NoError(t, WriteContainer(&header, Container{HeaderType: HeaderCanonical}))
+	assert.NoError(t, WriteCanonicalHeader(&header, lengths, 3))
+	err := Decode(&header, io.Discard)
+	assert.ErrorIs(t, err, ErrCorruptHeader)
+	assert.ErrorIs(t, err, ErrInvalidCodeLengths)
+}
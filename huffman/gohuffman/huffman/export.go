@@ -0,0 +1,97 @@
+package huffman
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteDOT renders the tree as a Graphviz digraph. Edges are labelled with
+// the bit they stand for and leaves with their symbol and weight. Trees built
+// from code lengths have no weights, so those show up as zero.
+func (t Tree) WriteDOT(w io.Writer, alphabet Alphabet) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph huffman {")
+	fmt.Fprintln(bw, "\tnode [shape=circle];")
+	if t.Root != nil {
+		id := 0
+		t.writeDOTNode(bw, t.Root, &id, alphabet)
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// writeDOTNode writes node and its subtree, numbering nodes in pre-order so
+// the same tree always gives the same output.
+func (t Tree) writeDOTNode(w io.Writer, node BaseNode, id *int, alphabet Alphabet) int {
+	self := *id
+	*id++
+	if node.IsLeaf() {
+		leaf := node.(*LeafNode)
+		label := fmt.Sprintf("%s\n%d", alphabet.SymbolString(leaf.Element), leaf.Priority())
+		fmt.Fprintf(w, "\tn%d [shape=box, label=\"%s\"];\n", self, dotEscape(label))
+		return self
+	}
+	internal := node.(*InternalNode)
+	fmt.Fprintf(w, "\tn%d [label=\"%d\"];\n", self, internal.Priority())
+	left := t.writeDOTNode(w, internal.Left, id, alphabet)
+	fmt.Fprintf(w, "\tn%d -> n%d [label=\"0\"];\n", self, left)
+	right := t.writeDOTNode(w, internal.Right, id, alphabet)
+	fmt.Fprintf(w, "\tn%d -> n%d [label=\"1\"];\n", self, right)
+	return self
+}
+
+func dotEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return strings.ReplaceAll(s, "\n", `\n`)
+}
+
+// SymbolString shows a symbol as a quoted rune or, for bytes, in hex.
+func (a Alphabet) SymbolString(r rune) string {
+	if a == ByteAlphabet {
+		return fmt.Sprintf("0x%02x", r)
+	}
+	return strconv.QuoteRune(r)
+}
+
+// jsonNode is a tree node as written by WriteJSON. Symbol is only set on
+// leaves, Left and Right only on internal nodes.
+type jsonNode struct {
+	Symbol *rune     `json:"symbol,omitempty"`
+	Text   string    `json:"text,omitempty"`
+	Weight int       `json:"weight"`
+	Path   string    `json:"path"`
+	Left   *jsonNode `json:"left,omitempty"`
+	Right  *jsonNode `json:"right,omitempty"`
+}
+
+// WriteJSON writes the tree as nested nodes holding their weight and path
+// from the root. Leaves also hold their symbol as a number and as text.
+func (t Tree) WriteJSON(w io.Writer, alphabet Alphabet) error {
+	var root *jsonNode
+	if t.Root != nil {
+		root = toJSON(t.Root, "", alphabet)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(root)
+}
+
+func toJSON(node BaseNode, path string, alphabet Alphabet) *jsonNode {
+	if node.IsLeaf() {
+		leaf := node.(*LeafNode)
+		symbol := leaf.Element
+		return &jsonNode{Symbol: &symbol, Text: alphabet.SymbolString(symbol), Weight: leaf.Priority(), Path: path}
+	}
+	internal := node.(*InternalNode)
+	return &jsonNode{
+		Weight: internal.Priority(),
+		Path:   path,
+		Left:   toJSON(internal.Left, path+"0", alphabet),
+		Right:  toJSON(internal.Right, path+"1", alphabet),
+	}
+}
@@ -0,0 +1,65 @@
+package huffman
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteDOT(t *testing.T) {
+	tree := CreateTree(freqOf(t, strings.NewReader(`aaae"1`), RuneAlphabet))
+	var dot bytes.Buffer
+	assert.NoError(t, tree.WriteDOT(&dot, RuneAlphabet))
+
+	expected := `digraph huffman {
+	node [shape=circle];
+	n0 [label="6"];
+	n1 [shape=box, label="'a'\n3"];
+	n0 -> n1 [label="0"];
+	n2 [label="3"];
+	n3 [shape=box, label="'e'\n1"];
+	n2 -> n3 [label="0"];
+	n4 [label="2"];
+	n5 [shape=box, label="'\"'\n1"];
+	n4 -> n5 [label="0"];
+	n6 [shape=box, label="'1'\n1"];
+	n4 -> n6 [label="1"];
+	n2 -> n4 [label="1"];
+	n0 -> n2 [label="1"];
+}
+`
+	assert.Equal(t, expected, dot.String())
+
+	dot.Reset()
+	assert.NoError(t, Tree{}.WriteDOT(&dot, RuneAlphabet))
+	assert.Equal(t, "digraph huffman {\n\tnode [shape=circle];\n}\n", dot.String())
+}
+
+func TestWriteJSONMatchesEncodingMap(t *testing.T) {
+	freqTable := freqOf(t, strings.NewReader(containerTestText), RuneAlphabet)
+	tree := CreateTree(freqTable)
+	var encoded bytes.Buffer
+	assert.NoError(t, tree.WriteJSON(&encoded, RuneAlphabet))
+
+	var root jsonNode
+	assert.NoError(t, json.Unmarshal(encoded.Bytes(), &root))
+	assert.Equal(t, freqTable.CharCount(), root.Weight)
+
+	paths := map[rune]string{}
+	var walk func(n *jsonNode)
+	walk = func(n *jsonNode) {
+		if n.Symbol != nil {
+			paths[*n.Symbol] = n.Path
+			assert.Equal(t, freqTable[*n.Symbol], n.Weight)
+			return
+		}
+		assert.Equal(t, n.Left.Weight+n.Right.Weight, n.Weight)
+		walk(n.Left)
+		walk(n.Right)
+	}
+	walk(&root)
+	assert.Equal(t, tree.BuildEncodingMap(), paths)
+}
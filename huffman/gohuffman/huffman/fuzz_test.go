@@ -0,0 +1,50 @@
+package huffman
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// fuzzLimits keeps fuzzed headers from asking for more than a test can afford.
+var fuzzLimits = DecoderOptions{MaxSymbols: 1 << 12, MaxCharCount: 1 << 16, MaxBlockSize: 1 << 16}
+
+func FuzzReadHeader(f *testing.F) {
+	for _, text := range []string{"", "a", "ab", containerTestText, "héllo wörld"} {
+		f.Add(encodeLegacy(f, text))
+	}
+	f.Add(freqHeader(-1, 0))
+	f.Add(freqHeader(2, 0, 'a', 5, 'b', -5))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := bytes.NewReader(data)
+		freqTable, charCount, err := ReadHeaderWith(r, fuzzLimits)
+		if err != nil {
+			return
+		}
+		if freqTable.CharCount() != charCount {
+			t.Fatalf("header accepted with %d symbols announced, frequencies add up to %d", charCount, freqTable.CharCount())
+		}
+		var out bytes.Buffer
+		err = DecodeAndWriteData(r, &out, CreateTree(freqTable), charCount)
+		if err == nil && out.Len() > charCount*4 {
+			t.Fatalf("decoded %d bytes from %d symbols", out.Len(), charCount)
+		}
+	})
+}
+
+func FuzzDecode(f *testing.F) {
+	for _, encoded := range everyFormat(f, containerTestText) {
+		f.Add(encoded)
+	}
+	f.Add(encodeLegacy(f, "legacy"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		zr, err := NewReaderOptions(bytes.NewReader(data), ReaderOptions{DecoderOptions: fuzzLimits})
+		if err != nil {
+			return
+		}
+		defer zr.Close()
+		io.Copy(io.Discard, zr)
+	})
+}
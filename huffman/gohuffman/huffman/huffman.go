@@ -3,7 +3,9 @@ package huffman
 import (
 	"bufio"
 	"encoding/binary"
+	"fmt"
 	"io"
+	"math"
 	"unicode/utf8"
 
 	"github.com/Ninad-Bhangui/gohuffman/bitreader"
@@ -13,6 +15,14 @@ import (
 
 type FreqTable map[rune]int
 
+func (f FreqTable) CharCount() int {
+	charCount := 0
+	for _, freq := range f {
+		charCount += freq
+	}
+	return charCount
+}
+
 type BaseNode interface {
 	IsLeaf() bool
 	Priority() int
@@ -78,7 +88,9 @@ type Tree struct {
 
 func (t Tree) BuildEncodingMap() map[rune]string {
 	encodedMap := make(map[rune]string)
-	t.walk(t.Root, "", encodedMap)
+	if t.Root != nil {
+		t.walk(t.Root, "", encodedMap)
+	}
 	return encodedMap
 }
 
@@ -93,24 +105,83 @@ func (t Tree) walk(node BaseNode, path string, m map[rune]string) {
 	t.walk(internal.Right, path+"1", m)
 }
 
-func CalculateFreq(reader io.Reader) FreqTable {
+// Alphabet selects what a symbol is: a UTF-8 decoded rune or a raw byte.
+// Coding invalid UTF-8 with the rune alphabet fails with ErrInvalidUTF8, so
+// binary data needs the byte alphabet.
+type Alphabet uint8
+
+const (
+	RuneAlphabet Alphabet = iota
+	ByteAlphabet
+)
+
+func (a Alphabet) String() string {
+	if a == ByteAlphabet {
+		return "byte"
+	}
+	return "rune"
+}
+
+func (a Alphabet) splitFunc() bufio.SplitFunc {
+	if a == ByteAlphabet {
+		return bufio.ScanBytes
+	}
+	return scanValidRunes
+}
+
+// scanValidRunes is bufio.ScanRunes, except that it fails on invalid UTF-8
+// instead of turning it into U+FFFD.
+func scanValidRunes(data []byte, atEOF bool) (int, []byte, error) {
+	advance, token, err := bufio.ScanRunes(data, atEOF)
+	if err == nil && advance > 0 {
+		if r, size := utf8.DecodeRune(data[:advance]); r == utf8.RuneError && size <= 1 {
+			return 0, nil, ErrInvalidUTF8
+		}
+	}
+	return advance, token, err
+}
+
+func (a Alphabet) symbol(token []byte) rune {
+	if a == ByteAlphabet {
+		return rune(token[0])
+	}
+	r, _ := utf8.DecodeRune(token)
+	return r
+}
+
+func (a Alphabet) appendSymbol(buf []byte, r rune) []byte {
+	if a == ByteAlphabet {
+		return append(buf, byte(r))
+	}
+	return utf8.AppendRune(buf, r)
+}
+
+func CalculateFreq(reader io.Reader) (FreqTable, error) {
+	return CalculateFreqWith(reader, RuneAlphabet)
+}
+
+func CalculateFreqWith(reader io.Reader, alphabet Alphabet) (FreqTable, error) {
 	freqTable := FreqTable{}
 	scanner := bufio.NewScanner(reader)
-	scanner.Split(bufio.ScanRunes)
+	scanner.Split(alphabet.splitFunc())
 	for scanner.Scan() {
-		text := scanner.Text()
-		rune := []rune(text)[0]
-		freqTable[rune]++
+		freqTable[alphabet.symbol(scanner.Bytes())]++
+	}
+	err := scanner.Err()
+	if err != nil {
+		return nil, err
 	}
-	return freqTable
+	return freqTable, nil
 }
 
+// WriteHeader writes the frequency table. Counts are 32 bits, so it fails
+// with ErrInputTooLarge for more than math.MaxInt32 symbols.
 func WriteHeader(w io.Writer, freqTable FreqTable) error {
 
 	entryCount := len(freqTable)
-	charCount := 0
-	for _, freq := range freqTable {
-		charCount += freq
+	charCount := freqTable.CharCount()
+	if charCount > math.MaxInt32 {
+		return fmt.Errorf("%w: %d symbols", ErrInputTooLarge, charCount)
 	}
 	err := binary.Write(w, binary.LittleEndian, int32(entryCount))
 	if err != nil {
@@ -134,38 +205,70 @@ func WriteHeader(w io.Writer, freqTable FreqTable) error {
 }
 
 func ReadHeader(r io.Reader) (FreqTable, int, error) {
+	return ReadHeaderWith(r, DecoderOptions{})
+}
+
+// ReadHeaderWith reads a frequency header, rejecting it unless the
+// frequencies are positive, the symbols are valid runes and the frequencies
+// add up to the symbol count.
+func ReadHeaderWith(r io.Reader, opts DecoderOptions) (FreqTable, int, error) {
 	entryCount := int32(0)
 	err := binary.Read(r, binary.LittleEndian, &entryCount)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, headerError(err)
 	}
 
 	charCount := int32(0)
 	err = binary.Read(r, binary.LittleEndian, &charCount)
+	if err != nil {
+		return nil, 0, headerError(err)
+	}
+	if entryCount < 0 || charCount < 0 {
+		return nil, 0, fmt.Errorf("%w: negative counts", ErrCorruptHeader)
+	}
+	if int(entryCount) > opts.maxSymbols() {
+		return nil, 0, fmt.Errorf("%w: %d symbols", ErrLimitExceeded, entryCount)
+	}
+	err = opts.checkCharCount(int(charCount))
 	if err != nil {
 		return nil, 0, err
 	}
 
-	freqTable := make(FreqTable, entryCount)
+	// Only trust the count as far as the entries are actually there.
+	freqTable := make(FreqTable, min(entryCount, 1024))
+	total := 0
 	for i := 0; i < int(entryCount); i++ {
 		key := int32(0)
 		err := binary.Read(r, binary.LittleEndian, &key)
 		if err != nil {
-			return nil, 0, err
+			return nil, 0, headerError(err)
 		}
 
 		freq := int32(0)
 		err = binary.Read(r, binary.LittleEndian, &freq)
 		if err != nil {
-			return nil, 0, err
+			return nil, 0, headerError(err)
 		}
 
+		if !utf8.ValidRune(key) || freq <= 0 {
+			return nil, 0, fmt.Errorf("%w: entry %d, frequency %d", ErrCorruptHeader, key, freq)
+		}
+		if _, ok := freqTable[key]; ok {
+			return nil, 0, fmt.Errorf("%w: duplicate entry %d", ErrCorruptHeader, key)
+		}
 		freqTable[rune(key)] = int(freq)
+		total += int(freq)
+	}
+	if total != int(charCount) {
+		return nil, 0, fmt.Errorf("%w: frequencies add up to %d, not %d", ErrCorruptHeader, total, charCount)
 	}
 
 	return freqTable, int(charCount), nil
 }
 
+// CreateTree builds the Huffman tree for freqTable. An empty table gives a
+// tree without a root, and a single symbol is the root itself, so it has a
+// code of no bits and only the symbol count says how often it repeats.
 func CreateTree(freqTable FreqTable) Tree {
 	pq := priorityqueue.New[BaseNode]()
 	for key, value := range freqTable {
@@ -180,43 +283,99 @@ func CreateTree(freqTable FreqTable) Tree {
 		pq.Enqueue(parent)
 	}
 
+	if pq.Size() == 0 {
+		return Tree{}
+	}
 	return Tree{Root: pq.Dequeue()}
 }
 
 func WriteData(reader io.Reader, writer io.Writer, encodedMap map[rune]string) error {
+	return WriteDataWith(reader, writer, encodedMap, RuneAlphabet)
+}
+
+func WriteDataWith(reader io.Reader, writer io.Writer, encodedMap map[rune]string, alphabet Alphabet) error {
 	bw := bitwriter.NewBitWriter(writer)
+	codes := packCodes(encodedMap)
 	scanner := bufio.NewScanner(reader)
-	scanner.Split(bufio.ScanRunes)
+	scanner.Split(alphabet.splitFunc())
 
-	// Collect all bits into one string
 	for scanner.Scan() {
-		text := scanner.Text()
-		runeValue := []rune(text)[0]
-		if code, ok := encodedMap[runeValue]; ok {
-			err := bw.WriteBitsFromString(code)
+		symbol := alphabet.symbol(scanner.Bytes())
+		if code, ok := codes[symbol]; ok {
+			err := code.write(&bw)
 			if err != nil {
 				return err
 			}
 		}
 	}
-	err := bw.Flush()
+	err := scanner.Err()
 	if err != nil {
 		return err
 	}
-	return nil
+	return bw.Flush()
+}
+
+// packedCode is a code as an integer, the first bit most significant. Only
+// the first 64 bits fit, so the rest of a longer code, which only very skewed
+// frequencies give, stays a string.
+type packedCode struct {
+	bits   uint64
+	length int
+	rest   string
+}
+
+func packCode(code string) packedCode {
+	c := packedCode{length: min(len(code), 64), rest: code[min(len(code), 64):]}
+	for _, bit := range code[:c.length] {
+		c.bits <<= 1
+		if bit == '1' {
+			c.bits |= 1
+		}
+	}
+	return c
+}
+
+func packCodes(encodedMap map[rune]string) map[rune]packedCode {
+	codes := make(map[rune]packedCode, len(encodedMap))
+	for r, code := range encodedMap {
+		codes[r] = packCode(code)
+	}
+	return codes
+}
+
+func (c packedCode) write(bw *bitwriter.BitWriter) error {
+	err := bw.WriteBits(c.bits, c.length)
+	if err != nil || c.rest == "" {
+		return err
+	}
+	return bw.WriteBitsFromString(c.rest)
 }
 
 func DecodeAndWriteData(reader io.Reader, writer io.Writer, tree Tree, charCount int) error {
+	return DecodeAndWriteDataWith(reader, writer, tree, charCount, RuneAlphabet)
+}
+
+func DecodeAndWriteDataWith(reader io.Reader, writer io.Writer, tree Tree, charCount int, alphabet Alphabet) error {
+	return decodeTableDriven(reader, writer, tree, charCount, alphabet)
+}
+
+// decodeTreeWalk follows the tree one bit at a time. It backs up the table
+// driven decoder for codes too long to hold in an integer.
+func decodeTreeWalk(reader io.Reader, writer io.Writer, tree Tree, charCount int, alphabet Alphabet) error {
 	r := bitreader.NewBitReader(reader)
 	currentNode := tree.Root
 	decodedCount := 0
 
 	for decodedCount < charCount {
+		if currentNode == nil {
+			return errInvalidCode
+		}
 		if currentNode.IsLeaf() {
 			node := currentNode.(*LeafNode)
-			runebyte := make([]byte, utf8.MaxRune)
-			n := utf8.EncodeRune(runebyte, node.Element)
-			writer.Write(runebyte[:n])
+			_, err := writer.Write(alphabet.appendSymbol(nil, node.Element))
+			if err != nil {
+				return err
+			}
 			currentNode = tree.Root
 			decodedCount++
 			continue
@@ -224,10 +383,7 @@ func DecodeAndWriteData(reader io.Reader, writer io.Writer, tree Tree, charCount
 
 		bit, err := r.ReadBit()
 		if err != nil {
-			if err == io.EOF {
-				return nil
-			}
-			return err
+			return unexpectedEOF(err)
 		}
 
 		node := currentNode.(*InternalNode)
@@ -1,16 +1,31 @@
 package huffman
 
 import (
+	"bytes"
+	"io"
+	"os"
 	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/stretchr/testify/assert"
 )
 
+// freqOf is CalculateFreqWith for inputs that cannot fail.
+func freqOf(tb testing.TB, r io.Reader, alphabet Alphabet) FreqTable {
+	tb.Helper()
+	freqTable, err := CalculateFreqWith(r, alphabet)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return freqTable
+}
+
 func TestCalculateFreq(t *testing.T) {
 	input := `aaaee1`
 
-	output := CalculateFreq(strings.NewReader(input))
+	output, err := CalculateFreq(strings.NewReader(input))
+	assert.NoError(t, err)
 	assert.Equal(t, 2, output['e'])
 	assert.Equal(t, 3, output['a'])
 	assert.Equal(t, 1, output['1'])
@@ -19,7 +34,7 @@ func TestCalculateFreq(t *testing.T) {
 func TestCreateTreeAndBuildEncodingMap(t *testing.T) {
 	input := `aaaee1`
 
-	freqTable := CalculateFreq(strings.NewReader(input))
+	freqTable := freqOf(t, strings.NewReader(input), RuneAlphabet)
 	tree := CreateTree(freqTable)
 	encodedMap := tree.BuildEncodingMap()
 
@@ -38,7 +53,7 @@ func TestCreateTreeAndBuildEncodingMap(t *testing.T) {
 func TestEncodeDecodeRoundtrip(t *testing.T) {
 	testText := "hello world! this is a test message with various characters 123"
 
-	freqTable := CalculateFreq(strings.NewReader(testText))
+	freqTable := freqOf(t, strings.NewReader(testText), RuneAlphabet)
 
 	tree := CreateTree(freqTable)
 	encodedMap := tree.BuildEncodingMap()
@@ -65,3 +80,297 @@ func TestEncodeDecodeRoundtrip(t *testing.T) {
 	decodedText := decodedBuffer.String()
 	assert.Equal(t, testText, decodedText)
 }
+
+func TestCanonicalEncodingMap(t *testing.T) {
+	lengths := CodeLengths{'a': 1, 'b': 3, 'c': 3, 'd': 2}
+
+	encodedMap := lengths.BuildEncodingMap()
+	assert.Equal(t, "0", encodedMap['a'])
+	assert.Equal(t, "10", encodedMap['d'])
+	assert.Equal(t, "110", encodedMap['b'])
+	assert.Equal(t, "111", encodedMap['c'])
+}
+
+func TestCanonicalCodesKeepTreeLengths(t *testing.T) {
+	testText := "hello world! this is a test message with various characters 123"
+
+	tree := CreateTree(freqOf(t, strings.NewReader(testText), RuneAlphabet))
+	lengths := tree.CodeLengths()
+	encodedMap := lengths.BuildEncodingMap()
+
+	for r, code := range tree.BuildEncodingMap() {
+		assert.Equal(t, len(code), len(encodedMap[r]))
+	}
+	for r, code := range encodedMap {
+		for other, otherCode := range encodedMap {
+			if r != other {
+				assert.False(t, strings.HasPrefix(otherCode, code))
+			}
+		}
+	}
+}
+
+func TestCanonicalEncodeDecodeRoundtrip(t *testing.T) {
+	testText := "hello world! this is a test message with various characters 123 ünïcödé"
+
+	freqTable := freqOf(t, strings.NewReader(testText), RuneAlphabet)
+	lengths := CreateTree(freqTable).CodeLengths()
+
+	var encodedBuffer strings.Builder
+	err := WriteCanonicalHeader(&encodedBuffer, lengths, freqTable.CharCount())
+	assert.NoError(t, err)
+	err = WriteData(strings.NewReader(testText), &encodedBuffer, lengths.BuildEncodingMap())
+	assert.NoError(t, err)
+
+	encodedReader := strings.NewReader(encodedBuffer.String())
+	decodedLengths, charCount, err := ReadCanonicalHeader(encodedReader)
+	assert.NoError(t, err)
+	assert.Equal(t, lengths, decodedLengths)
+	assert.Equal(t, len([]rune(testText)), charCount)
+
+	tree, err := CreateCanonicalTree(decodedLengths)
+	assert.NoError(t, err)
+
+	var decodedBuffer strings.Builder
+	err = DecodeAndWriteData(encodedReader, &decodedBuffer, tree, charCount)
+	assert.NoError(t, err)
+	assert.Equal(t, testText, decodedBuffer.String())
+}
+
+func TestCanonicalHeaderIsSmaller(t *testing.T) {
+	testText := "hello world! this is a test message with various characters 123 ünïcödé"
+
+	freqTable := freqOf(t, strings.NewReader(testText), RuneAlphabet)
+	lengths := CreateTree(freqTable).CodeLengths()
+
+	var freqHeader, canonicalHeader strings.Builder
+	assert.NoError(t, WriteHeader(&freqHeader, freqTable))
+	assert.NoError(t, WriteCanonicalHeader(&canonicalHeader, lengths, freqTable.CharCount()))
+	assert.Less(t, canonicalHeader.Len(), freqHeader.Len())
+}
+
+func TestCreateCanonicalTreeRejectsInvalidLengths(t *testing.T) {
+	_, err := CreateCanonicalTree(CodeLengths{'a': 1, 'b': 1, 'c': 1})
+	assert.ErrorIs(t, err, ErrInvalidCodeLengths)
+
+	_, err = CreateCanonicalTree(CodeLengths{'a': 1, 'b': 2})
+	assert.ErrorIs(t, err, ErrInvalidCodeLengths)
+
+	_, err = CreateCanonicalTree(CodeLengths{'a': 1})
+	assert.ErrorIs(t, err, ErrInvalidCodeLengths)
+}
+
+func TestDegenerateTrees(t *testing.T) {
+	tests := []struct {
+		name      string
+		freqTable FreqTable
+		codes     map[rune]string
+	}{
+		{"empty", FreqTable{}, map[rune]string{}},
+		{"single symbol", FreqTable{'x': 7}, map[rune]string{'x': ""}},
+		{"two symbols", FreqTable{'x': 7, 'y': 1}, map[rune]string{'x': "1", 'y': "0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree := CreateTree(tt.freqTable)
+			assert.Equal(t, tt.codes, tree.BuildEncodingMap())
+
+			lengths := tree.CodeLengths()
+			canonical, err := CreateCanonicalTree(lengths)
+			assert.NoError(t, err)
+			assert.Equal(t, lengths, canonical.CodeLengths())
+
+			limited, err := LimitedCodeLengths(tt.freqTable, 4)
+			assert.NoError(t, err)
+			assert.Equal(t, lengths, limited)
+		})
+	}
+}
+
+func TestDegenerateInputsRoundtrip(t *testing.T) {
+	inputs := map[string]string{
+		"empty":         "",
+		"single symbol": "a",
+		"single run":    strings.Repeat("z", 1000),
+		"single rune":   strings.Repeat("界", 10),
+	}
+	for name, input := range inputs {
+		for format, encoded := range everyFormat(t, input) {
+			t.Run(name+"/"+format, func(t *testing.T) {
+				var decoded bytes.Buffer
+				assert.NoError(t, Decode(bytes.NewReader(encoded), &decoded))
+				assert.Equal(t, input, decoded.String())
+
+				zr, err := NewReader(bytes.NewReader(encoded))
+				assert.NoError(t, err)
+				decoded.Reset()
+				_, err = io.Copy(&decoded, zr)
+				assert.NoError(t, err)
+				assert.Equal(t, input, decoded.String())
+			})
+		}
+	}
+}
+
+func TestSingleSymbolIsCodedWithoutBits(t *testing.T) {
+	freqTable := FreqTable{'a': 10}
+	var data bytes.Buffer
+	assert.NoError(t, WriteData(strings.NewReader(strings.Repeat("a", 10)), &data, CreateTree(freqTable).BuildEncodingMap()))
+	assert.Equal(t, 0, data.Len())
+
+	var decoded bytes.Buffer
+	assert.NoError(t, DecodeAndWriteData(bytes.NewReader(nil), &decoded, CreateTree(freqTable), 10))
+	assert.Equal(t, strings.Repeat("a", 10), decoded.String())
+}
+
+func TestByteAlphabetRoundtrip(t *testing.T) {
+	data := []byte{0x1f, 0x8b, 0xff, 0xfe, 0x00, 0xc3, 'a', 'a', 0xe2, 0x82, 0xac, 0x80}
+	for i := 0; i < 256; i++ {
+		data = append(data, byte(i))
+	}
+
+	freqTable := freqOf(t, bytes.NewReader(data), ByteAlphabet)
+	assert.Equal(t, len(data), freqTable.CharCount())
+	assert.Equal(t, 256, len(freqTable))
+
+	tree := CreateTree(freqTable)
+	var encoded bytes.Buffer
+	err := WriteDataWith(bytes.NewReader(data), &encoded, tree.BuildEncodingMap(), ByteAlphabet)
+	assert.NoError(t, err)
+
+	var decoded bytes.Buffer
+	err = DecodeAndWriteDataWith(&encoded, &decoded, tree, freqTable.CharCount(), ByteAlphabet)
+	assert.NoError(t, err)
+	assert.Equal(t, data, decoded.Bytes())
+}
+
+func TestRuneAlphabetRejectsInvalidUTF8(t *testing.T) {
+	for _, data := range []string{"a\xffb", "ab\xe4\xb8"} {
+		_, err := CalculateFreqWith(strings.NewReader(data), RuneAlphabet)
+		assert.ErrorIs(t, err, ErrInvalidUTF8)
+		err = WriteDataWith(strings.NewReader(data), io.Discard, map[rune]string{'a': "0", 'b': "1"}, RuneAlphabet)
+		assert.ErrorIs(t, err, ErrInvalidUTF8)
+		err = WriteAdaptiveData(strings.NewReader(data), io.Discard, RuneAlphabet)
+		assert.ErrorIs(t, err, ErrInvalidUTF8)
+		err = EncodeStream(strings.NewReader(data), io.Discard, WriterOptions{Alphabet: RuneAlphabet})
+		assert.ErrorIs(t, err, ErrInvalidUTF8)
+	}
+
+	// U+FFFD itself is valid.
+	freqTable := freqOf(t, strings.NewReader("a\uFFFDb"), RuneAlphabet)
+	assert.Equal(t, 1, freqTable[utf8.RuneError])
+}
+
+func fibonacciFreqTable(n int) FreqTable {
+	freqTable := FreqTable{}
+	a, b := 1, 1
+	for i := 0; i < n; i++ {
+		freqTable[rune('A'+i)] = a
+		a, b = b, a+b
+	}
+	return freqTable
+}
+
+func TestDecodeLongCodesWithSubtables(t *testing.T) {
+	freqTable := fibonacciFreqTable(30)
+	tree := CreateTree(freqTable)
+	encodedMap := tree.BuildEncodingMap()
+
+	longest := 0
+	for _, code := range encodedMap {
+		longest = max(longest, len(code))
+	}
+	assert.Greater(t, longest, 2*tableBits)
+
+	var text strings.Builder
+	for r := range freqTable {
+		text.WriteString(strings.Repeat(string(r), 3))
+	}
+	input := text.String()
+
+	var encoded bytes.Buffer
+	assert.NoError(t, WriteData(strings.NewReader(input), &encoded, encodedMap))
+
+	var tableDecoded, walkDecoded strings.Builder
+	charCount := len([]rune(input))
+	assert.NoError(t, DecodeAndWriteData(bytes.NewReader(encoded.Bytes()), &tableDecoded, tree, charCount))
+	assert.NoError(t, decodeTreeWalk(bytes.NewReader(encoded.Bytes()), &walkDecoded, tree, charCount, RuneAlphabet))
+	assert.Equal(t, input, tableDecoded.String())
+	assert.Equal(t, input, walkDecoded.String())
+}
+
+func TestDecodeTruncatedData(t *testing.T) {
+	testText := "hello world! this is a test message with various characters 123"
+	freqTable := freqOf(t, strings.NewReader(testText), RuneAlphabet)
+	tree := CreateTree(freqTable)
+
+	var encoded bytes.Buffer
+	assert.NoError(t, WriteData(strings.NewReader(testText), &encoded, tree.BuildEncodingMap()))
+
+	truncated := encoded.Bytes()[:encoded.Len()/2]
+	err := DecodeAndWriteData(bytes.NewReader(truncated), io.Discard, tree, freqTable.CharCount())
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestCodesLongerThan64Bits(t *testing.T) {
+	freqTable := fibonacciFreqTable(80)
+	tree := CreateTree(freqTable)
+	encodedMap := tree.BuildEncodingMap()
+	assert.Equal(t, 79, len(encodedMap['A']))
+
+	input := "ABAB" + string(rune('A'+79))
+	var encoded bytes.Buffer
+	assert.NoError(t, WriteData(strings.NewReader(input), &encoded, encodedMap))
+
+	var decoded strings.Builder
+	assert.NoError(t, DecodeAndWriteData(bytes.NewReader(encoded.Bytes()), &decoded, tree, len([]rune(input))))
+	assert.Equal(t, input, decoded.String())
+}
+
+func benchmarkDecode(b *testing.B, decode func(io.Reader, io.Writer, Tree, int, Alphabet) error) {
+	data, err := os.ReadFile("../samples/test.txt")
+	if err != nil {
+		b.Fatal(err)
+	}
+	freqTable := freqOf(b, bytes.NewReader(data), RuneAlphabet)
+	tree := CreateTree(freqTable)
+	var encoded bytes.Buffer
+	err = WriteData(bytes.NewReader(data), &encoded, tree.BuildEncodingMap())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := decode(bytes.NewReader(encoded.Bytes()), io.Discard, tree, freqTable.CharCount(), RuneAlphabet)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeTableDriven(b *testing.B) {
+	benchmarkDecode(b, decodeTableDriven)
+}
+
+func BenchmarkDecodeTreeWalk(b *testing.B) {
+	benchmarkDecode(b, decodeTreeWalk)
+}
+
+func BenchmarkWriteData(b *testing.B) {
+	data, err := os.ReadFile("../samples/test.txt")
+	if err != nil {
+		b.Fatal(err)
+	}
+	encodedMap := CreateTree(freqOf(b, bytes.NewReader(data), RuneAlphabet)).BuildEncodingMap()
+
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := WriteData(bytes.NewReader(data), io.Discard, encodedMap)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
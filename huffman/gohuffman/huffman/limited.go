@@ -0,0 +1,101 @@
+package huffman
+
+import (
+	"fmt"
+	"sort"
+)
+
+// pmItem is a coin in the package-merge algorithm: either a single symbol or
+// a package of two items from the level below.
+type pmItem struct {
+	weight      int
+	symbol      rune
+	left, right *pmItem
+}
+
+func (item *pmItem) countSymbols(lengths CodeLengths) {
+	if item.left == nil {
+		lengths[item.symbol]++
+		return
+	}
+	item.left.countSymbols(lengths)
+	item.right.countSymbols(lengths)
+}
+
+// LimitedCodeLengths returns optimal code lengths for freqTable where no code
+// is longer than maxLen bits, using the package-merge algorithm.
+func LimitedCodeLengths(freqTable FreqTable, maxLen int) (CodeLengths, error) {
+	n := len(freqTable)
+	if maxLen < 1 || maxLen > maxCodeLength {
+		return nil, fmt.Errorf("huffman: max code length must be between 1 and %d, got %d", maxCodeLength, maxLen)
+	}
+	if n > 1 && maxLen < 63 && n > 1<<maxLen {
+		return nil, fmt.Errorf("huffman: %d symbols do not fit in codes of %d bits", n, maxLen)
+	}
+	lengths := CodeLengths{}
+	if n <= 1 {
+		for r := range freqTable {
+			lengths[r] = 0
+		}
+		return lengths, nil
+	}
+
+	leaves := make([]*pmItem, 0, n)
+	for r, freq := range freqTable {
+		leaves = append(leaves, &pmItem{weight: freq, symbol: r})
+	}
+	sort.Slice(leaves, func(i, j int) bool {
+		if leaves[i].weight == leaves[j].weight {
+			return leaves[i].symbol < leaves[j].symbol
+		}
+		return leaves[i].weight < leaves[j].weight
+	})
+
+	// Start at the deepest level and package towards level one, where the
+	// cheapest 2n-2 items decide how deep every symbol goes.
+	var packages []*pmItem
+	list := leaves
+	for level := maxLen; level > 1; level-- {
+		packages = packages[:0]
+		for i := 0; i+1 < len(list); i += 2 {
+			packages = append(packages, &pmItem{weight: list[i].weight + list[i+1].weight, left: list[i], right: list[i+1]})
+		}
+		list = mergeItems(leaves, packages)
+	}
+	for _, item := range list[:2*n-2] {
+		item.countSymbols(lengths)
+	}
+	return lengths, nil
+}
+
+// mergeItems merges two lists sorted by weight, leaves first on ties.
+func mergeItems(leaves, packages []*pmItem) []*pmItem {
+	merged := make([]*pmItem, 0, len(leaves)+len(packages))
+	i, j := 0, 0
+	for i < len(leaves) || j < len(packages) {
+		if j == len(packages) || (i < len(leaves) && leaves[i].weight <= packages[j].weight) {
+			merged = append(merged, leaves[i])
+			i++
+		} else {
+			merged = append(merged, packages[j])
+			j++
+		}
+	}
+	return merged
+}
+
+// codeLengths picks the code lengths an encoder uses for freqTable. Codes
+// follow the Huffman tree unless maxLen, or the longest code a canonical
+// header can describe, calls for a limit.
+func codeLengths(freqTable FreqTable, maxLen int) (CodeLengths, error) {
+	if maxLen > 0 {
+		return LimitedCodeLengths(freqTable, maxLen)
+	}
+	lengths := CreateTree(freqTable).CodeLengths()
+	for _, length := range lengths {
+		if length > maxCodeLength {
+			return LimitedCodeLengths(freqTable, maxCodeLength)
+		}
+	}
+	return lengths, nil
+}
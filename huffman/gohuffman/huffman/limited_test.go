@@ -0,0 +1,91 @@
+package huffman
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func cost(freqTable FreqTable, lengths CodeLengths) int {
+	total := 0
+	for r, freq := range freqTable {
+		total += freq * lengths[r]
+	}
+	return total
+}
+
+func TestLimitedCodeLengthsSmall(t *testing.T) {
+	freqTable := FreqTable{'a': 1, 'b': 1, 'c': 2, 'd': 4}
+
+	lengths, err := LimitedCodeLengths(freqTable, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, CodeLengths{'a': 3, 'b': 3, 'c': 2, 'd': 1}, lengths)
+
+	lengths, err = LimitedCodeLengths(freqTable, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, CodeLengths{'a': 2, 'b': 2, 'c': 2, 'd': 2}, lengths)
+}
+
+func TestLimitedCodeLengthsFibonacci(t *testing.T) {
+	tests := []struct {
+		symbols int
+		maxLen  int
+	}{
+		{20, 8},
+		{30, 10},
+		{40, 15},
+		{45, 32},
+	}
+	for _, tt := range tests {
+		freqTable := fibonacciFreqTable(tt.symbols)
+		unlimited := CreateTree(freqTable).CodeLengths()
+		longest := 0
+		for _, length := range unlimited {
+			longest = max(longest, length)
+		}
+		assert.Greater(t, longest, tt.maxLen, "the unlimited code should exceed the limit")
+
+		lengths, err := LimitedCodeLengths(freqTable, tt.maxLen)
+		assert.NoError(t, err)
+		assert.Len(t, lengths, tt.symbols)
+		for _, length := range lengths {
+			assert.LessOrEqual(t, length, tt.maxLen)
+		}
+		assert.NoError(t, lengths.validate())
+		assert.GreaterOrEqual(t, cost(freqTable, lengths), cost(freqTable, unlimited))
+	}
+}
+
+func TestLimitedCodeLengthsMatchesHuffmanWhenLoose(t *testing.T) {
+	freqTable := freqOf(t, strings.NewReader(containerTestText), RuneAlphabet)
+	lengths, err := LimitedCodeLengths(freqTable, maxCodeLength)
+	assert.NoError(t, err)
+	assert.Equal(t, cost(freqTable, CreateTree(freqTable).CodeLengths()), cost(freqTable, lengths))
+}
+
+func TestLimitedCodeLengthsRejectsImpossibleLimits(t *testing.T) {
+	_, err := LimitedCodeLengths(fibonacciFreqTable(5), 2)
+	assert.Error(t, err)
+	_, err = LimitedCodeLengths(fibonacciFreqTable(5), 0)
+	assert.Error(t, err)
+}
+
+func TestLimitedCodesRoundtrip(t *testing.T) {
+	var text strings.Builder
+	for r, freq := range fibonacciFreqTable(20) {
+		text.WriteString(strings.Repeat(string(r), freq))
+	}
+
+	var compressed bytes.Buffer
+	zw, err := NewWriterOptions(&compressed, WriterOptions{MaxCodeLength: 8})
+	assert.NoError(t, err)
+	_, err = zw.Write([]byte(text.String()))
+	assert.NoError(t, err)
+	assert.NoError(t, zw.Close())
+
+	var decoded bytes.Buffer
+	assert.NoError(t, Decode(&compressed, &decoded))
+	assert.Equal(t, text.String(), decoded.String())
+}
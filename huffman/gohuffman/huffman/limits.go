@@ -0,0 +1,65 @@
+package huffman
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// DefaultMaxSymbols lets a header list every rune and a dictionary's escape
+// symbol.
+const DefaultMaxSymbols = utf8.MaxRune + 2
+
+// DefaultMaxBlockSize is the largest block decoded by default. Blocks are
+// decoded in memory, so this bounds memory use.
+const DefaultMaxBlockSize = 1 << 28
+
+// DecoderOptions bounds what a header may make the decoder allocate or
+// produce. Zero fields take the defaults.
+type DecoderOptions struct {
+	// MaxSymbols is the most symbols a header may list.
+	MaxSymbols int
+	// MaxCharCount is the most symbols a header may announce, which bounds
+	// the size of the decoded data. Zero means no limit.
+	MaxCharCount int
+	// MaxBlockSize is the largest decoded block accepted.
+	MaxBlockSize int
+}
+
+func (o DecoderOptions) maxSymbols() int {
+	if o.MaxSymbols <= 0 {
+		return DefaultMaxSymbols
+	}
+	return o.MaxSymbols
+}
+
+func (o DecoderOptions) maxBlockSize() int {
+	if o.MaxBlockSize <= 0 {
+		return DefaultMaxBlockSize
+	}
+	return o.MaxBlockSize
+}
+
+func (o DecoderOptions) checkCharCount(charCount int) error {
+	if o.MaxCharCount > 0 && charCount > o.MaxCharCount {
+		return fmt.Errorf("%w: %d symbols announced", ErrLimitExceeded, charCount)
+	}
+	return nil
+}
+
+// validSymbol reports whether r can occur in data of the alphabet.
+func (a Alphabet) validSymbol(r rune) bool {
+	if a == ByteAlphabet {
+		return r >= 0 && r <= 0xff
+	}
+	return utf8.ValidRune(r)
+}
+
+// checkSymbols rejects a header whose symbols do not belong to the alphabet.
+func checkSymbols[V any](symbols map[rune]V, alphabet Alphabet) error {
+	for r := range symbols {
+		if !alphabet.validSymbol(r) {
+			return fmt.Errorf("%w: symbol %d is not in the %s alphabet", ErrCorruptHeader, r, alphabet)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package huffman
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// freqHeader builds a frequency header from raw fields, valid or not.
+func freqHeader(entryCount, charCount int32, entries ...int32) []byte {
+	var buf []byte
+	for _, v := range append([]int32{entryCount, charCount}, entries...) {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(v))
+	}
+	return buf
+}
+
+func TestReadHeaderRejectsBadHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []byte
+		err    error
+	}{
+		{"negative entry count", freqHeader(-1, 0), ErrCorruptHeader},
+		{"negative char count", freqHeader(1, -5, 'a', 1), ErrCorruptHeader},
+		{"huge entry count", freqHeader(1<<30, 1<<30), ErrLimitExceeded},
+		{"negative frequency", freqHeader(2, 0, 'a', 5, 'b', -5), ErrCorruptHeader},
+		{"zero frequency", freqHeader(1, 0, 'a', 0), ErrCorruptHeader},
+		{"invalid rune", freqHeader(1, 1, 0xd800, 1), ErrCorruptHeader},
+		{"rune out of range", freqHeader(1, 1, 0x110000, 1), ErrCorruptHeader},
+		{"duplicate entry", freqHeader(2, 2, 'a', 1, 'a', 1), ErrCorruptHeader},
+		{"wrong char count", freqHeader(2, 7, 'a', 1, 'b', 2), ErrCorruptHeader},
+		{"missing entries", freqHeader(1000, 1000, 'a', 1), ErrTruncatedData},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := ReadHeader(bytes.NewReader(tt.header))
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestReadHeaderWithLimits(t *testing.T) {
+	header := freqHeader(3, 6, 'a', 1, 'b', 2, 'c', 3)
+
+	_, _, err := ReadHeaderWith(bytes.NewReader(header), DecoderOptions{MaxSymbols: 2})
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+	_, _, err = ReadHeaderWith(bytes.NewReader(header), DecoderOptions{MaxCharCount: 5})
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+
+	freqTable, charCount, err := ReadHeaderWith(bytes.NewReader(header), DecoderOptions{MaxSymbols: 3, MaxCharCount: 6})
+	assert.NoError(t, err)
+	assert.Equal(t, FreqTable{'a': 1, 'b': 2, 'c': 3}, freqTable)
+	assert.Equal(t, 6, charCount)
+}
+
+// uvarints encodes values one after the other.
+func uvarints(values ...uint64) []byte {
+	var buf []byte
+	for _, v := range values {
+		buf = binary.AppendUvarint(buf, v)
+	}
+	return buf
+}
+
+func TestHeadersRejectTooManySymbols(t *testing.T) {
+	err := WriteHeader(io.Discard, FreqTable{'a': math.MaxInt32, 'b': 1})
+	assert.ErrorIs(t, err, ErrInputTooLarge)
+	err = WriteCanonicalHeader(io.Discard, CodeLengths{'a': 1, 'b': 1}, math.MaxInt32+1)
+	assert.ErrorIs(t, err, ErrInputTooLarge)
+
+	// The largest count still round-trips.
+	var header bytes.Buffer
+	assert.NoError(t, WriteCanonicalHeader(&header, CodeLengths{'a': 1, 'b': 1}, math.MaxInt32))
+	_, charCount, err := ReadCanonicalHeader(&header)
+	assert.NoError(t, err)
+	assert.Equal(t, math.MaxInt32, charCount)
+}
+
+func TestReadCanonicalHeaderRejectsBadHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []byte
+		err    error
+	}{
+		{"huge entry count", uvarints(1<<40, 1), ErrLimitExceeded},
+		{"huge char count", uvarints(1, 1<<40), ErrCorruptHeader},
+		{"repeated symbol", append(append(uvarints(2, 2, 'a'), 1), append(uvarints(0), 1)...), ErrCorruptHeader},
+		{"symbol out of range", append(uvarints(1, 1, 1<<30), 1), ErrCorruptHeader},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := ReadCanonicalHeader(bytes.NewReader(tt.header))
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteCanonicalHeader(&buf, CodeLengths{'a': 1, 'b': 1}, 10))
+	_, _, err := ReadCanonicalHeaderWith(bytes.NewReader(buf.Bytes()), DecoderOptions{MaxCharCount: 9})
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+}
+
+func TestReaderLimits(t *testing.T) {
+	text := strings.Repeat("limits ", 100)
+	encoded := encodeContainer(t, text, Container{HeaderType: HeaderFreq, Flags: FlagChecksum})
+	_, err := NewReaderOptions(bytes.NewReader(encoded), ReaderOptions{DecoderOptions: DecoderOptions{MaxCharCount: 10}})
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+
+	var blocks bytes.Buffer
+	assert.NoError(t, EncodeStream(strings.NewReader(text), &blocks, WriterOptions{BlockSize: 64}))
+	zr, err := NewReaderOptions(bytes.NewReader(blocks.Bytes()), ReaderOptions{DecoderOptions: DecoderOptions{MaxBlockSize: 32}})
+	assert.NoError(t, err)
+	_, err = io.ReadAll(zr)
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+}
+
+func TestAdaptiveReaderLimits(t *testing.T) {
+	text := strings.Repeat("limits ", 100)
+	var encoded bytes.Buffer
+	assert.NoError(t, EncodeAdaptive(strings.NewReader(text), &encoded, RuneAlphabet))
+
+	limits := []DecoderOptions{{MaxCharCount: 10}, {MaxSymbols: 5}}
+	for _, opts := range limits {
+		zr, err := NewReaderOptions(bytes.NewReader(encoded.Bytes()), ReaderOptions{DecoderOptions: opts})
+		assert.NoError(t, err)
+		_, err = io.ReadAll(zr)
+		assert.ErrorIs(t, err, ErrLimitExceeded)
+	}
+
+	zr, err := NewReaderOptions(bytes.NewReader(encoded.Bytes()), ReaderOptions{DecoderOptions: DecoderOptions{MaxCharCount: len(text), MaxSymbols: 6}})
+	assert.NoError(t, err)
+	decoded, err := io.ReadAll(zr)
+	assert.NoError(t, err)
+	assert.Equal(t, text, string(decoded))
+}
+
+func TestBlockReaderLimits(t *testing.T) {
+	text := strings.Repeat("limits ", 150)
+	var encoded bytes.Buffer
+	assert.NoError(t, EncodeStream(strings.NewReader(text), &encoded, WriterOptions{BlockSize: 64}))
+
+	// Every block is within the limit on its own, but not all of them.
+	limits := []DecoderOptions{{MaxSymbols: 2}, {MaxCharCount: 100}}
+	for _, opts := range limits {
+		for _, workers := range []int{1, 4} {
+			zr, err := NewReaderOptions(bytes.NewReader(encoded.Bytes()), ReaderOptions{DecoderOptions: opts, Workers: workers})
+			assert.NoError(t, err)
+			_, err = io.ReadAll(zr)
+			assert.ErrorIs(t, err, ErrLimitExceeded)
+		}
+	}
+
+	zr, err := NewReaderOptions(bytes.NewReader(encoded.Bytes()), ReaderOptions{DecoderOptions: DecoderOptions{MaxCharCount: len(text), MaxSymbols: 6}})
+	assert.NoError(t, err)
+	decoded, err := io.ReadAll(zr)
+	assert.NoError(t, err)
+	assert.Equal(t, text, string(decoded))
+}
+
+func TestBlockPayloadIsBoundedByBlockSize(t *testing.T) {
+	// A one byte block claiming a 64 MiB payload is rejected before the
+	// payload is read.
+	var encoded bytes.Buffer
+	assert.NoError(t, WriteContainer(&encoded, Container{HeaderType: HeaderCanonical, Flags: FlagBlocks}))
+	encoded.Write(uvarints(1, 64<<20))
+	encoded.Write(make([]byte, 1024))
+	zr, err := NewReaderOptions(bytes.NewReader(encoded.Bytes()), ReaderOptions{DecoderOptions: DecoderOptions{MaxBlockSize: 16}})
+	assert.NoError(t, err)
+	_, err = io.ReadAll(zr)
+	assert.ErrorIs(t, err, ErrCorruptData)
+	assert.NotErrorIs(t, err, ErrTruncatedData)
+}
+
+func TestDictionaryReaderLimits(t *testing.T) {
+	d := trainTestDictionary(t, RuneAlphabet)
+	var encoded bytes.Buffer
+	assert.NoError(t, EncodeDictionary(strings.NewReader(dictionaryCorpus[0]), &encoded, d))
+
+	opts := ReaderOptions{Dictionary: d, DecoderOptions: DecoderOptions{MaxCharCount: 10}}
+	zr, err := NewReaderOptions(bytes.NewReader(encoded.Bytes()), opts)
+	assert.NoError(t, err)
+	_, err = io.ReadAll(zr)
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+
+	opts.MaxCharCount = len(dictionaryCorpus[0])
+	zr, err = NewReaderOptions(bytes.NewReader(encoded.Bytes()), opts)
+	assert.NoError(t, err)
+	decoded, err := io.ReadAll(zr)
+	assert.NoError(t, err)
+	assert.Equal(t, dictionaryCorpus[0], string(decoded))
+}
+
+func TestByteAlphabetRejectsRunes(t *testing.T) {
+	var encoded bytes.Buffer
+	assert.NoError(t, WriteContainer(&encoded, Container{HeaderType: HeaderFreq, Flags: FlagByteAlphabet}))
+	assert.NoError(t, WriteHeader(&encoded, FreqTable{'a': 1, '世': 1}))
+	encoded.WriteByte(0x40)
+	err := Decode(bytes.NewReader(encoded.Bytes()), io.Discard)
+	assert.ErrorIs(t, err, ErrCorruptHeader)
+}
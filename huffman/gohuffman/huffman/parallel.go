@@ -0,0 +1,99 @@
+package huffman
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+var errBadIndex = fmt.Errorf("%w: block index does not match the blocks", ErrCorruptData)
+
+type blockResult struct {
+	rawLen int64
+	data   []byte
+	err    error
+}
+
+// blockQueue codes blocks on their own goroutines and hands the results back
+// in the order the blocks were added. Callers keep at most as many blocks
+// queued as they want to code at once.
+type blockQueue struct {
+	pending []chan blockResult
+}
+
+func (q *blockQueue) add(rawLen int64, job func() ([]byte, error)) {
+	ch := make(chan blockResult, 1)
+	go func() {
+		data, err := job()
+		ch <- blockResult{rawLen: rawLen, data: data, err: err}
+	}()
+	q.pending = append(q.pending, ch)
+}
+
+func (q *blockQueue) len() int {
+	return len(q.pending)
+}
+
+// next waits for the oldest block.
+func (q *blockQueue) next() blockResult {
+	result := <-q.pending[0]
+	q.pending = q.pending[1:]
+	return result
+}
+
+// indexEntry describes one block in the block index.
+type indexEntry struct {
+	// rawLen is the length of the original data.
+	rawLen int64
+	// length is how many bytes the coded block takes up.
+	length int64
+}
+
+// appendIndex appends the block index that follows the end of the stream
+// when FlagIndex is set: the uvarint block count, the uvarint original and
+// coded length of every block, and the length of all that as a little endian
+// uint64, so the index can also be found from the end of the file.
+func appendIndex(buf []byte, index []indexEntry) []byte {
+	start := len(buf)
+	buf = binary.AppendUvarint(buf, uint64(len(index)))
+	for _, e := range index {
+		buf = binary.AppendUvarint(buf, uint64(e.rawLen))
+		buf = binary.AppendUvarint(buf, uint64(e.length))
+	}
+	return binary.LittleEndian.AppendUint64(buf, uint64(len(buf)-start))
+}
+
+func readIndex(br *bufio.Reader) ([]indexEntry, error) {
+	count, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	var index []indexEntry
+	for i := uint64(0); i < count; i++ {
+		rawLen, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		length, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if rawLen > 1<<62 || length > 1<<62 {
+			return nil, errBadIndex
+		}
+		index = append(index, indexEntry{rawLen: int64(rawLen), length: int64(length)})
+	}
+	var size uint64
+	err = binary.Read(br, binary.LittleEndian, &size)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	// The writer never pads its uvarints, so the index re-encodes to the same
+	// bytes.
+	encoded := appendIndex(nil, index)
+	if !bytes.Equal(encoded[len(encoded)-8:], binary.LittleEndian.AppendUint64(nil, size)) {
+		return nil, errBadIndex
+	}
+	return index, nil
+}
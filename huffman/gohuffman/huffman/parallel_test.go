@@ -0,0 +1,96 @@
+package huffman
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func encodeWorkers(t *testing.T, text string, workers int) []byte {
+	var encoded bytes.Buffer
+	err := EncodeStream(strings.NewReader(text), &encoded, WriterOptions{BlockSize: 100, Workers: workers})
+	assert.NoError(t, err)
+	return encoded.Bytes()
+}
+
+func TestParallelMatchesSequential(t *testing.T) {
+	text := strings.Repeat(streamTestText, 40)
+	sequential := encodeWorkers(t, text, 1)
+	for _, workers := range []int{2, 3, 8} {
+		encoded := encodeWorkers(t, text, workers)
+		assert.Equal(t, sequential, encoded, "%d workers", workers)
+
+		zr, err := NewReaderOptions(bytes.NewReader(encoded), ReaderOptions{Workers: workers})
+		assert.NoError(t, err)
+		decoded, err := io.ReadAll(zr)
+		assert.NoError(t, err)
+		assert.Equal(t, text, string(decoded), "%d workers", workers)
+	}
+}
+
+func TestBlockIndex(t *testing.T) {
+	text := strings.Repeat(streamTestText, 10)
+	encoded := encodeWorkers(t, text, 4)
+
+	size := binary.LittleEndian.Uint64(encoded[len(encoded)-8:])
+	br := bytes.NewReader(encoded[len(encoded)-8-int(size):])
+	index, err := readIndex(bufio.NewReader(br))
+	assert.NoError(t, err)
+
+	// The index describes every block, and the blocks end with the end
+	// marker right before it.
+	var rawTotal, total int64
+	for _, e := range index {
+		rawTotal += e.rawLen
+		total += e.length
+	}
+	assert.Equal(t, int64(len(text)), rawTotal)
+	headerSize := int64(len(Magic) + 3)
+	assert.Equal(t, int64(len(encoded))-headerSize-1-8-int64(size), total)
+}
+
+func TestReaderRejectsBadIndex(t *testing.T) {
+	encoded := encodeWorkers(t, strings.Repeat(streamTestText, 10), 2)
+	size := binary.LittleEndian.Uint64(encoded[len(encoded)-8:])
+
+	// Claim the first block is one byte longer than it is.
+	corrupted := bytes.Clone(encoded)
+	corrupted[len(corrupted)-8-int(size)+1]++
+	err := Decode(bytes.NewReader(corrupted), io.Discard)
+	assert.ErrorIs(t, err, ErrCorruptData)
+	assert.NotErrorIs(t, err, ErrChecksumMismatch)
+
+	assert.ErrorIs(t, Decode(bytes.NewReader(encoded[:len(encoded)-4]), io.Discard), io.ErrUnexpectedEOF)
+}
+
+func TestParallelReaderKeepsBlocksBeforeError(t *testing.T) {
+	text := strings.Repeat("abcdefghij", 50)
+	encoded := encodeWorkers(t, text, 1)
+
+	// Find the last block and damage its checksum, so only that one fails.
+	offset := len(Magic) + 3
+	br := bufio.NewReader(bytes.NewReader(encoded[offset:]))
+	checksumAt := 0
+	for {
+		b, done, err := readRawBlock(br, Container{Flags: FlagChecksum}, DecoderOptions{})
+		assert.NoError(t, err)
+		if done {
+			break
+		}
+		checksumAt = offset + int(b.length) - len(b.payload) - 4
+		offset += int(b.length)
+	}
+	corrupted := bytes.Clone(encoded)
+	corrupted[checksumAt] ^= 0xff
+
+	zr, err := NewReaderOptions(bytes.NewReader(corrupted), ReaderOptions{Workers: 4})
+	assert.NoError(t, err)
+	decoded, err := io.ReadAll(zr)
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+	assert.Equal(t, text[:400], string(decoded))
+}
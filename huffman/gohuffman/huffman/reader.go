@@ -0,0 +1,162 @@
+package huffman
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+)
+
+type ReaderOptions struct {
+	DecoderOptions
+	// Workers is how many blocks are decoded at once. Zero means one.
+	Workers int
+	// Dictionary is needed for data coded with EncodeDictionary.
+	Dictionary *Dictionary
+}
+
+// Reader decompresses any container written by this package, and legacy
+// files, much like gzip.Reader.
+type Reader struct {
+	br    *bufio.Reader
+	c     Container
+	opts  DecoderOptions
+	block bytes.Buffer
+	// pipe carries the output of formats that are not made of blocks, which
+	// are decoded by a goroutine writing into it.
+	pipe *io.PipeReader
+	err  error
+
+	workers int
+	queue   blockQueue
+	// index lists the blocks read so far, to check the block index against.
+	index []indexEntry
+	// readErr is set once no more blocks can be read. It is only reported
+	// after the blocks before it.
+	readErr error
+	// charCount is how many symbols the blocks read so far announce.
+	charCount int
+}
+
+// NewReader reads the container from r and returns a Reader for its data.
+// It fails straight away if r is not in a known format.
+func NewReader(r io.Reader) (*Reader, error) {
+	return NewReaderOptions(r, ReaderOptions{})
+}
+
+func NewReaderOptions(r io.Reader, opts ReaderOptions) (*Reader, error) {
+	if opts.Workers < 0 {
+		return nil, fmt.Errorf("huffman: invalid worker count %d", opts.Workers)
+	}
+	br := bufio.NewReader(r)
+	c, err := ReadContainer(br)
+	if err != nil {
+		return nil, err
+	}
+	zr := &Reader{br: br, c: c, opts: opts.DecoderOptions, workers: max(opts.Workers, 1)}
+	if c.Flags&FlagBlocks != 0 {
+		return zr, nil
+	}
+
+	decode := func(w io.Writer) error {
+		return decodeAdaptiveBody(br, w, c, opts.DecoderOptions)
+	}
+	if c.HeaderType == HeaderDictionary {
+		id, err := readDictionaryID(br)
+		if err != nil {
+			return nil, err
+		}
+		err = dictionaryFor(c, id, opts.Dictionary)
+		if err != nil {
+			return nil, err
+		}
+		decode = func(w io.Writer) error {
+			return decodeDictionaryBody(br, w, c, opts.Dictionary, opts.DecoderOptions)
+		}
+	} else if c.HeaderType != HeaderAdaptive {
+		// Read the header here so that foreign input is reported right away.
+		tree, charCount, err := readTree(br, c, opts.DecoderOptions)
+		if err != nil {
+			return nil, err
+		}
+		decode = func(w io.Writer) error {
+			return decodeTreeData(br, w, c, tree, charCount)
+		}
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(decode(pw))
+	}()
+	zr.pipe = pr
+	return zr, nil
+}
+
+// Container returns the container fields read by NewReader.
+func (z *Reader) Container() Container {
+	return z.c
+}
+
+func (z *Reader) Read(p []byte) (int, error) {
+	if z.pipe != nil {
+		return z.pipe.Read(p)
+	}
+	for z.block.Len() == 0 {
+		if z.err != nil {
+			return 0, z.err
+		}
+		for z.readErr == nil && z.queue.len() < z.workers {
+			z.readErr = z.queueBlock()
+		}
+		if z.queue.len() == 0 {
+			z.err = z.readErr
+			continue
+		}
+		// Nothing from a block that fails its checks is handed out.
+		result := z.queue.next()
+		z.block.Reset()
+		z.block.Write(result.data)
+		z.err = result.err
+	}
+	return z.block.Read(p)
+}
+
+// queueBlock reads the next block and queues it for decoding. At the end of
+// the stream it checks the block index and returns io.EOF.
+func (z *Reader) queueBlock() error {
+	b, done, err := readRawBlock(z.br, z.c, z.opts)
+	if err != nil {
+		return err
+	}
+	if done {
+		if z.c.Flags&FlagIndex != 0 {
+			index, err := readIndex(z.br)
+			if err != nil {
+				return err
+			}
+			if !slices.Equal(index, z.index) {
+				return errBadIndex
+			}
+		}
+		return io.EOF
+	}
+	z.charCount += b.charCount
+	err = z.opts.checkCharCount(z.charCount)
+	if err != nil {
+		return err
+	}
+	z.index = append(z.index, indexEntry{rawLen: int64(b.rawLen), length: b.length})
+	c, opts := z.c, z.opts
+	z.queue.add(int64(b.rawLen), func() ([]byte, error) {
+		return b.decode(c, opts)
+	})
+	return nil
+}
+
+// Close stops decoding. It does not close the underlying reader.
+func (z *Reader) Close() error {
+	if z.pipe != nil {
+		return z.pipe.Close()
+	}
+	return nil
+}
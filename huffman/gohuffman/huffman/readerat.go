@@ -0,0 +1,138 @@
+package huffman
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// ReaderAt gives random access to the data of a block container with a block
+// index, so reading from the middle of a large file only decodes the blocks
+// that are needed.
+type ReaderAt struct {
+	r io.ReaderAt
+	c Container
+	// rawOffsets and offsets hold where every block starts, in the original
+	// data and in r. Both have one extra entry for the end.
+	rawOffsets []int64
+	offsets    []int64
+
+	mu sync.Mutex
+	// cached is the number of the block in cache, or -1.
+	cached int
+	cache  []byte
+}
+
+// NewReaderAt reads the container and block index of r, which is size bytes
+// long. It returns ErrNoIndex if the container has no block index.
+func NewReaderAt(r io.ReaderAt, size int64) (*ReaderAt, error) {
+	c, err := ReadContainer(bufio.NewReader(io.NewSectionReader(r, 0, size)))
+	if err != nil {
+		return nil, err
+	}
+	if c.HeaderType == HeaderLegacy || c.Flags&FlagIndex == 0 {
+		return nil, ErrNoIndex
+	}
+
+	var footer [8]byte
+	if size < int64(len(footer)) {
+		return nil, ErrTruncatedData
+	}
+	_, err = r.ReadAt(footer[:], size-int64(len(footer)))
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	indexSize := int64(binary.LittleEndian.Uint64(footer[:]))
+	// The end marker is the single byte right before the index.
+	dataEnd := size - int64(len(footer)) - indexSize - 1
+	if indexSize > size || dataEnd < 0 {
+		return nil, errBadIndex
+	}
+	index, err := readIndex(bufio.NewReader(io.NewSectionReader(r, dataEnd+1, size-dataEnd-1)))
+	if err != nil {
+		return nil, err
+	}
+
+	ra := &ReaderAt{r: r, c: c, cached: -1}
+	var rawOffset, offset int64
+	for _, e := range index {
+		ra.rawOffsets = append(ra.rawOffsets, rawOffset)
+		ra.offsets = append(ra.offsets, offset)
+		rawOffset += e.rawLen
+		offset += e.length
+	}
+	// Blocks start right after the container fields.
+	dataStart := dataEnd - offset
+	if dataStart != int64(len(Magic)+3) {
+		return nil, errBadIndex
+	}
+	for i := range ra.offsets {
+		ra.offsets[i] += dataStart
+	}
+	ra.rawOffsets = append(ra.rawOffsets, rawOffset)
+	ra.offsets = append(ra.offsets, dataEnd)
+	return ra, nil
+}
+
+// Container returns the container fields read by NewReaderAt.
+func (ra *ReaderAt) Container() Container {
+	return ra.c
+}
+
+// Size returns the length of the original data.
+func (ra *ReaderAt) Size() int64 {
+	return ra.rawOffsets[len(ra.rawOffsets)-1]
+}
+
+// ReadAt implements io.ReaderAt on the original data. It may be called from
+// several goroutines at once.
+func (ra *ReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("huffman: negative offset %d", off)
+	}
+	n := 0
+	for n < len(p) {
+		if off >= ra.Size() {
+			return n, io.EOF
+		}
+		// The last block whose start is at or before off.
+		i := sort.Search(len(ra.rawOffsets), func(i int) bool { return ra.rawOffsets[i] > off }) - 1
+		block, err := ra.block(i)
+		if err != nil {
+			return n, err
+		}
+		copied := copy(p[n:], block[off-ra.rawOffsets[i]:])
+		n += copied
+		off += int64(copied)
+	}
+	return n, nil
+}
+
+// block returns the decoded block i. The last block read is kept, since reads
+// tend to be sequential.
+func (ra *ReaderAt) block(i int) ([]byte, error) {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	if ra.cached == i {
+		return ra.cache, nil
+	}
+
+	length := ra.offsets[i+1] - ra.offsets[i]
+	br := bufio.NewReader(io.NewSectionReader(ra.r, ra.offsets[i], length))
+	b, done, err := readRawBlock(br, ra.c, DecoderOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if done || b.length != length || int64(b.rawLen) != ra.rawOffsets[i+1]-ra.rawOffsets[i] {
+		return nil, errBadIndex
+	}
+	block, err := b.decode(ra.c, DecoderOptions{})
+	if err != nil {
+		return nil, err
+	}
+	ra.cached, ra.cache = i, block
+	return block, nil
+}
@@ -0,0 +1,65 @@
+package huffman
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReaderAtReadsAnyRange(t *testing.T) {
+	text := strings.Repeat(streamTestText, 20)
+	encoded := encodeWorkers(t, text, 2)
+	ra, err := NewReaderAt(bytes.NewReader(encoded), int64(len(encoded)))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(text)), ra.Size())
+
+	for _, r := range [][2]int{{0, 10}, {95, 10}, {250, 700}, {0, len(text)}, {len(text) - 3, 3}} {
+		buf := make([]byte, r[1])
+		n, err := ra.ReadAt(buf, int64(r[0]))
+		assert.NoError(t, err)
+		assert.Equal(t, text[r[0]:r[0]+r[1]], string(buf[:n]), "range %v", r)
+	}
+
+	section, err := io.ReadAll(io.NewSectionReader(ra, 123, 456))
+	assert.NoError(t, err)
+	assert.Equal(t, text[123:123+456], string(section))
+
+	buf := make([]byte, 10)
+	n, err := ra.ReadAt(buf, int64(len(text))-4)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, text[len(text)-4:], string(buf[:n]))
+	_, err = ra.ReadAt(buf, -1)
+	assert.Error(t, err)
+}
+
+func TestReaderAtEmptyInput(t *testing.T) {
+	encoded := encodeWorkers(t, "", 1)
+	ra, err := NewReaderAt(bytes.NewReader(encoded), int64(len(encoded)))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), ra.Size())
+	_, err = ra.ReadAt(make([]byte, 1), 0)
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestReaderAtNeedsIndex(t *testing.T) {
+	encoded := encodeContainer(t, containerTestText, Container{HeaderType: HeaderCanonical})
+	_, err := NewReaderAt(bytes.NewReader(encoded), int64(len(encoded)))
+	assert.ErrorIs(t, err, ErrNoIndex)
+
+	encoded = encodeWorkers(t, containerTestText, 1)
+	_, err = NewReaderAt(bytes.NewReader(encoded), int64(len(encoded)-1))
+	assert.Error(t, err)
+}
+
+func TestReaderAtDetectsCorruption(t *testing.T) {
+	text := strings.Repeat(streamTestText, 5)
+	encoded := encodeWorkers(t, text, 1)
+	encoded[len(Magic)+3+20] ^= 0x10
+	ra, err := NewReaderAt(bytes.NewReader(encoded), int64(len(encoded)))
+	assert.NoError(t, err)
+	_, err = ra.ReadAt(make([]byte, 10), 0)
+	assert.Error(t, err)
+}
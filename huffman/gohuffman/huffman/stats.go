@@ -0,0 +1,84 @@
+package huffman
+
+import (
+	"math"
+	"sort"
+)
+
+type SymbolStats struct {
+	Symbol     rune `json:"symbol"`
+	Freq       int  `json:"freq"`
+	CodeLength int  `json:"code_length"`
+}
+
+// Stats describes how well a code fits the data it was built for. Sizes are
+// in bytes, Entropy and AverageCodeLength in bits per symbol.
+type Stats struct {
+	OriginalSize      int64         `json:"original_size"`
+	CompressedSize    int64         `json:"compressed_size"`
+	HeaderSize        int64         `json:"header_size"`
+	Ratio             float64       `json:"ratio"`
+	Entropy           float64       `json:"entropy"`
+	AverageCodeLength float64       `json:"average_code_length"`
+	Symbols           []SymbolStats `json:"symbols"`
+}
+
+// Entropy returns the Shannon entropy of freqTable in bits per symbol, the
+// lower bound for the average length of any prefix code.
+func Entropy(freqTable FreqTable) float64 {
+	total := float64(freqTable.CharCount())
+	entropy := 0.0
+	for _, freq := range freqTable {
+		if freq > 0 {
+			p := float64(freq) / total
+			entropy -= p * math.Log2(p)
+		}
+	}
+	return entropy
+}
+
+// AverageCodeLength returns the mean number of bits per symbol when
+// freqTable is coded with lengths.
+func AverageCodeLength(freqTable FreqTable, lengths CodeLengths) float64 {
+	total := freqTable.CharCount()
+	if total == 0 {
+		return 0
+	}
+	bits := 0
+	for r, freq := range freqTable {
+		bits += freq * lengths[r]
+	}
+	return float64(bits) / float64(total)
+}
+
+// NewStats fills in everything but the sizes. Symbols are listed from the
+// most to the least frequent.
+func NewStats(freqTable FreqTable, lengths CodeLengths) Stats {
+	s := Stats{
+		Entropy:           Entropy(freqTable),
+		AverageCodeLength: AverageCodeLength(freqTable, lengths),
+		Symbols:           make([]SymbolStats, 0, len(freqTable)),
+	}
+	for r, freq := range freqTable {
+		s.Symbols = append(s.Symbols, SymbolStats{Symbol: r, Freq: freq, CodeLength: lengths[r]})
+	}
+	sort.Slice(s.Symbols, func(i, j int) bool {
+		if s.Symbols[i].Freq == s.Symbols[j].Freq {
+			return s.Symbols[i].Symbol < s.Symbols[j].Symbol
+		}
+		return s.Symbols[i].Freq > s.Symbols[j].Freq
+	})
+	return s
+}
+
+// SetSizes records the sizes and the compression ratio, compressed size over
+// original size.
+func (s *Stats) SetSizes(original, compressed, header int64) {
+	s.OriginalSize = original
+	s.CompressedSize = compressed
+	s.HeaderSize = header
+	s.Ratio = 0
+	if original > 0 {
+		s.Ratio = float64(compressed) / float64(original)
+	}
+}
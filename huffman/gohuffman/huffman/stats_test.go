@@ -0,0 +1,41 @@
+package huffman
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEntropy(t *testing.T) {
+	assert.Equal(t, 0.0, Entropy(FreqTable{}))
+	assert.Equal(t, 0.0, Entropy(FreqTable{'a': 7}))
+	assert.InDelta(t, 1.0, Entropy(FreqTable{'a': 3, 'b': 3}), 1e-9)
+	assert.InDelta(t, 2.0, Entropy(FreqTable{'a': 1, 'b': 1, 'c': 1, 'd': 1}), 1e-9)
+	assert.InDelta(t, 1.5, Entropy(FreqTable{'a': 2, 'b': 1, 'c': 1}), 1e-9)
+}
+
+func TestStatsWithinEntropyBound(t *testing.T) {
+	freqTable := freqOf(t, strings.NewReader(containerTestText), RuneAlphabet)
+	lengths := CreateTree(freqTable).CodeLengths()
+	s := NewStats(freqTable, lengths)
+
+	// A Huffman code is never better than the entropy and always less than
+	// a bit per symbol worse.
+	assert.GreaterOrEqual(t, s.AverageCodeLength, s.Entropy)
+	assert.Less(t, s.AverageCodeLength, s.Entropy+1)
+
+	assert.Len(t, s.Symbols, len(freqTable))
+	for i, sym := range s.Symbols {
+		assert.Equal(t, freqTable[sym.Symbol], sym.Freq)
+		assert.Equal(t, lengths[sym.Symbol], sym.CodeLength)
+		if i > 0 {
+			assert.LessOrEqual(t, sym.Freq, s.Symbols[i-1].Freq)
+		}
+	}
+
+	s.SetSizes(200, 50, 10)
+	assert.Equal(t, 0.25, s.Ratio)
+	s.SetSizes(0, 10, 10)
+	assert.Equal(t, 0.0, s.Ratio)
+}
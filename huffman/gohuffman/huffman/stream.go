@@ -0,0 +1,211 @@
+package huffman
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"unicode/utf8"
+)
+
+const DefaultBlockSize = 1 << 20
+
+var errBadBlockLength = fmt.Errorf("%w: block does not decode to its length", ErrCorruptData)
+
+// EncodeStream compresses r in a single pass so it works on pipes and stdin.
+// Input is buffered opts.BlockSize bytes at a time and every block is written
+// with its own canonical code, so memory use is bounded by the block size.
+func EncodeStream(r io.Reader, w io.Writer, opts WriterOptions) error {
+	zw, err := NewWriterOptions(w, opts)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(zw, r)
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// incompleteRuneSuffix returns how many bytes at the end of data belong to a
+// rune that is cut off.
+func incompleteRuneSuffix(data []byte) int {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if utf8.FullRune(data[i:]) {
+				return 0
+			}
+			return len(data) - i
+		}
+	}
+	return 0
+}
+
+// encodeBlock codes data as the uvarint length of the original data, the
+// uvarint length of the payload, a CRC-32 of the original data and the
+// payload: a canonical header followed by the coded bits. A block with a zero
+// length ends the stream.
+func encodeBlock(data []byte, c Container, maxCodeLength int) ([]byte, error) {
+	alphabet := c.Alphabet()
+	freqTable, err := CalculateFreqWith(bytes.NewReader(data), alphabet)
+	if err != nil {
+		return nil, err
+	}
+	lengths, err := codeLengths(freqTable, maxCodeLength)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload bytes.Buffer
+	err = WriteCanonicalHeader(&payload, lengths, freqTable.CharCount())
+	if err != nil {
+		return nil, err
+	}
+	err = WriteDataWith(bytes.NewReader(data), &payload, lengths.BuildEncodingMap(), alphabet)
+	if err != nil {
+		return nil, err
+	}
+
+	block := binary.AppendUvarint(nil, uint64(len(data)))
+	block = binary.AppendUvarint(block, uint64(payload.Len()))
+	if c.Flags&FlagChecksum != 0 {
+		block = binary.LittleEndian.AppendUint32(block, crc32.ChecksumIEEE(data))
+	}
+	return append(block, payload.Bytes()...), nil
+}
+
+func decodeBlocks(br *bufio.Reader, w io.Writer, c Container, opts DecoderOptions) error {
+	zr := &Reader{br: br, c: c, opts: opts, workers: 1}
+	_, err := io.Copy(w, zr)
+	return err
+}
+
+// rawBlock is a block that has been read but not decoded yet.
+type rawBlock struct {
+	rawLen   uint64
+	checksum uint32
+	payload  []byte
+	// charCount is the symbol count announced by the block's header.
+	charCount int
+	// length is how many bytes the block takes up in the stream.
+	length int64
+}
+
+// readRawBlock reads the next block. done is true once the end of the stream
+// is reached.
+func readRawBlock(br *bufio.Reader, c Container, opts DecoderOptions) (b rawBlock, done bool, err error) {
+	b.rawLen, err = binary.ReadUvarint(br)
+	if err != nil {
+		return b, false, unexpectedEOF(err)
+	}
+	if b.rawLen == 0 {
+		return b, true, nil
+	}
+	if b.rawLen > uint64(opts.maxBlockSize()) {
+		return b, false, fmt.Errorf("%w: block of %d bytes", ErrLimitExceeded, b.rawLen)
+	}
+	payloadLen, err := binary.ReadUvarint(br)
+	if err != nil {
+		return b, false, unexpectedEOF(err)
+	}
+	header := binary.AppendUvarint(binary.AppendUvarint(nil, b.rawLen), payloadLen)
+	if c.Flags&FlagChecksum != 0 {
+		err = binary.Read(br, binary.LittleEndian, &b.checksum)
+		if err != nil {
+			return b, false, unexpectedEOF(err)
+		}
+		header = binary.LittleEndian.AppendUint32(header, b.checksum)
+	}
+	if payloadLen > maxPayloadLen(b.rawLen) {
+		return b, false, fmt.Errorf("%w: %d bytes coding a block of %d", ErrCorruptData, payloadLen, b.rawLen)
+	}
+	b.payload, err = io.ReadAll(io.LimitReader(br, int64(payloadLen)))
+	if err != nil {
+		return b, false, err
+	}
+	if uint64(len(b.payload)) != payloadLen {
+		return b, false, ErrTruncatedData
+	}
+	// The canonical header starts with the number of entries, then the
+	// number of symbols.
+	_, n := binary.Uvarint(b.payload)
+	if n <= 0 {
+		return b, false, headerError(io.ErrUnexpectedEOF)
+	}
+	charCount, m := binary.Uvarint(b.payload[n:])
+	if m <= 0 {
+		return b, false, headerError(io.ErrUnexpectedEOF)
+	}
+	if charCount > b.rawLen {
+		return b, false, fmt.Errorf("%w: more symbols than bytes", ErrCorruptHeader)
+	}
+	b.charCount = int(charCount)
+	b.length = int64(len(header) + len(b.payload))
+	return b, false, nil
+}
+
+// maxPayloadLen is the longest payload a block of rawLen bytes can have: a
+// header listing rawLen symbols and that many codes of the longest length.
+func maxPayloadLen(rawLen uint64) uint64 {
+	headerLen := 2*binary.MaxVarintLen64 + rawLen*(binary.MaxVarintLen32+1)
+	return headerLen + (rawLen*maxCodeLength+7)/8
+}
+
+// decode decodes the block and checks it against its length and checksum.
+func (b rawBlock) decode(c Container, opts DecoderOptions) ([]byte, error) {
+	var block bytes.Buffer
+	err := decodeBlock(b.payload, &block, c.Alphabet(), int(b.rawLen), opts)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(block.Len()) != b.rawLen {
+		return nil, errBadBlockLength
+	}
+	if c.Flags&FlagChecksum != 0 && crc32.ChecksumIEEE(block.Bytes()) != b.checksum {
+		return nil, ErrChecksumMismatch
+	}
+	return block.Bytes(), nil
+}
+
+// decodeBlock decodes a block that decodes to rawLen bytes. Every symbol
+// takes at least a byte, which bounds the symbol count. opts.MaxSymbols
+// bounds the header, while opts.MaxCharCount applies to the whole stream and
+// is left to the caller.
+func decodeBlock(payload []byte, w io.Writer, alphabet Alphabet, rawLen int, opts DecoderOptions) error {
+	r := bytes.NewReader(payload)
+	lengths, charCount, err := ReadCanonicalHeaderWith(r, DecoderOptions{MaxSymbols: opts.MaxSymbols})
+	if err == nil && charCount > rawLen {
+		err = fmt.Errorf("%w: more symbols than bytes", ErrCorruptHeader)
+	}
+	if err == nil {
+		err = checkSymbols(lengths, alphabet)
+	}
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	tree, err := CreateCanonicalTree(lengths)
+	if err != nil {
+		return err
+	}
+	return DecodeAndWriteDataWith(r, w, tree, charCount, alphabet)
+}
+
+// unexpectedEOF reports running out of input in the middle of a structure as
+// ErrTruncatedData.
+func unexpectedEOF(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrTruncatedData
+	}
+	return err
+}
+
+// headerError reports running out of input in the middle of a header as a
+// corrupt header as well as truncated data.
+func headerError(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: %w", ErrCorruptHeader, ErrTruncatedData)
+	}
+	return err
+}
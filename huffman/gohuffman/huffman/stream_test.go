@@ -0,0 +1,96 @@
+package huffman
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const streamTestText = "héllo wörld! ünïcödé text split across blocks, 日本語 too. "
+
+func TestEncodeStreamRoundtrip(t *testing.T) {
+	text := strings.Repeat(streamTestText, 20)
+	for _, blockSize := range []int{1, 5, 64, 1000, DefaultBlockSize} {
+		for _, alphabet := range []Alphabet{RuneAlphabet, ByteAlphabet} {
+			var encoded bytes.Buffer
+			err := EncodeStream(strings.NewReader(text), &encoded, WriterOptions{Alphabet: alphabet, BlockSize: blockSize})
+			assert.NoError(t, err)
+
+			var decoded bytes.Buffer
+			err = Decode(&encoded, &decoded)
+			assert.NoError(t, err)
+			assert.Equal(t, text, decoded.String(), "block size %d, %s alphabet", blockSize, alphabet)
+		}
+	}
+}
+
+func TestEncodeStreamFromPipe(t *testing.T) {
+	text := strings.Repeat(streamTestText, 50)
+	pr, pw := io.Pipe()
+	go func() {
+		for i := 0; i < len(text); i += 7 {
+			pw.Write([]byte(text[i:min(i+7, len(text))]))
+		}
+		pw.Close()
+	}()
+
+	var encoded bytes.Buffer
+	err := EncodeStream(pr, &encoded, WriterOptions{BlockSize: 100})
+	assert.NoError(t, err)
+
+	var decoded bytes.Buffer
+	assert.NoError(t, Decode(&encoded, &decoded))
+	assert.Equal(t, text, decoded.String())
+}
+
+func TestEncodeStreamEmptyInput(t *testing.T) {
+	var encoded bytes.Buffer
+	assert.NoError(t, EncodeStream(strings.NewReader(""), &encoded, WriterOptions{BlockSize: 100}))
+
+	var decoded bytes.Buffer
+	assert.NoError(t, Decode(&encoded, &decoded))
+	assert.Equal(t, 0, decoded.Len())
+}
+
+func TestDecodeBlocksDetectsCorruption(t *testing.T) {
+	var encoded bytes.Buffer
+	err := EncodeStream(strings.NewReader(strings.Repeat(streamTestText, 5)), &encoded, WriterOptions{BlockSize: 64})
+	assert.NoError(t, err)
+	data := encoded.Bytes()
+
+	err = Decode(bytes.NewReader(data[:len(data)-1]), io.Discard)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	corrupted := bytes.Clone(data)
+	corrupted[len(corrupted)-3] ^= 0x20
+	assert.Error(t, Decode(bytes.NewReader(corrupted), io.Discard))
+}
+
+func TestBlockLengthMismatchIsCorruptData(t *testing.T) {
+	// Without checksums, a block that decodes to fewer bytes than it claims
+	// is malformed rather than a checksum failure.
+	c := Container{HeaderType: HeaderCanonical, Flags: FlagBlocks | FlagByteAlphabet}
+	block, err := encodeBlock([]byte("abcabc"), c, maxCodeLength)
+	assert.NoError(t, err)
+	block[0]++
+
+	var encoded bytes.Buffer
+	assert.NoError(t, WriteContainer(&encoded, c))
+	encoded.Write(block)
+	encoded.WriteByte(0)
+	err = Decode(bytes.NewReader(encoded.Bytes()), io.Discard)
+	assert.ErrorIs(t, err, ErrCorruptData)
+	assert.NotErrorIs(t, err, ErrChecksumMismatch)
+}
+
+func TestIncompleteRuneSuffix(t *testing.T) {
+	euro := []byte("€")
+	assert.Equal(t, 0, incompleteRuneSuffix([]byte("abc")))
+	assert.Equal(t, 0, incompleteRuneSuffix(append([]byte("a"), euro...)))
+	assert.Equal(t, 1, incompleteRuneSuffix(append([]byte("a"), euro[:1]...)))
+	assert.Equal(t, 2, incompleteRuneSuffix(append([]byte("a"), euro[:2]...)))
+	assert.Equal(t, 0, incompleteRuneSuffix([]byte{'a', 0xff}))
+}
@@ -0,0 +1,183 @@
+package huffman
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+var errWriterClosed = errors.New("huffman: write to closed Writer")
+
+type WriterOptions struct {
+	Alphabet Alphabet
+	// BlockSize is how many bytes are buffered and coded together.
+	// DefaultBlockSize is used if it is zero. It may not exceed
+	// DefaultMaxBlockSize, or decoders would reject the blocks.
+	BlockSize int
+	// MaxCodeLength limits how long a code may get. Zero means no limit
+	// beyond what the canonical header can describe.
+	MaxCodeLength int
+	// Workers is how many blocks are coded at once. Zero means one.
+	Workers int
+}
+
+// Writer compresses everything written to it into a block container, much
+// like gzip.Writer. Data is coded a block at a time, so the output is only
+// complete once Close is called. Up to Workers blocks are coded in parallel
+// and written in order, followed by a block index.
+type Writer struct {
+	w             io.Writer
+	c             Container
+	blockSize     int
+	maxCodeLength int
+	workers       int
+	queue         blockQueue
+	index         []indexEntry
+	buf           []byte
+	wroteHeader   bool
+	closed        bool
+	err           error
+}
+
+// NewWriter returns a Writer using the byte alphabet, which round-trips any
+// data.
+func NewWriter(w io.Writer) *Writer {
+	zw, _ := NewWriterOptions(w, WriterOptions{Alphabet: ByteAlphabet})
+	return zw
+}
+
+func NewWriterOptions(w io.Writer, opts WriterOptions) (*Writer, error) {
+	if opts.Alphabet != RuneAlphabet && opts.Alphabet != ByteAlphabet {
+		return nil, fmt.Errorf("huffman: invalid alphabet %d", opts.Alphabet)
+	}
+	if opts.BlockSize < 0 || opts.BlockSize > DefaultMaxBlockSize {
+		return nil, fmt.Errorf("huffman: invalid block size %d", opts.BlockSize)
+	}
+	if opts.MaxCodeLength < 0 || opts.MaxCodeLength > maxCodeLength {
+		return nil, fmt.Errorf("huffman: invalid max code length %d", opts.MaxCodeLength)
+	}
+	if opts.Workers < 0 {
+		return nil, fmt.Errorf("huffman: invalid worker count %d", opts.Workers)
+	}
+	blockSize := opts.BlockSize
+	if blockSize == 0 {
+		blockSize = DefaultBlockSize
+	}
+	c := Container{HeaderType: HeaderCanonical, Flags: FlagBlocks | FlagChecksum | FlagIndex}
+	if opts.Alphabet == ByteAlphabet {
+		c.Flags |= FlagByteAlphabet
+	}
+	return &Writer{
+		w: w,
+		c: c,
+		// A block must fit a whole rune, otherwise it could never be written.
+		blockSize:     max(blockSize, utf8.UTFMax),
+		maxCodeLength: opts.MaxCodeLength,
+		workers:       max(opts.Workers, 1),
+	}, nil
+}
+
+func (z *Writer) Write(p []byte) (int, error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+	if z.closed {
+		return 0, errWriterClosed
+	}
+	written := 0
+	for len(p) > 0 {
+		take := min(z.blockSize-len(z.buf), len(p))
+		z.buf = append(z.buf, p[:take]...)
+		p = p[take:]
+		written += take
+		if len(z.buf) == z.blockSize {
+			z.err = z.writeBuffered(false)
+			if z.err != nil {
+				return written, z.err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Flush writes the buffered data as a block. With the rune alphabet, the
+// bytes of a rune that is not complete yet stay buffered.
+func (z *Writer) Flush() error {
+	if z.err != nil {
+		return z.err
+	}
+	if z.closed {
+		return errWriterClosed
+	}
+	z.err = z.writeBuffered(false)
+	if z.err != nil {
+		return z.err
+	}
+	z.err = z.writeQueued(0)
+	return z.err
+}
+
+// Close writes the remaining data and the end of the stream. It does not
+// close the underlying writer.
+func (z *Writer) Close() error {
+	if z.err != nil {
+		return z.err
+	}
+	if z.closed {
+		return nil
+	}
+	z.closed = true
+	z.err = z.writeBuffered(true)
+	if z.err != nil {
+		return z.err
+	}
+	z.err = z.writeQueued(0)
+	if z.err != nil {
+		return z.err
+	}
+	_, z.err = z.w.Write(appendIndex(binary.AppendUvarint(nil, 0), z.index))
+	return z.err
+}
+
+func (z *Writer) writeBuffered(final bool) error {
+	if !z.wroteHeader {
+		err := WriteContainer(z.w, z.c)
+		if err != nil {
+			return err
+		}
+		z.wroteHeader = true
+	}
+	block := z.buf
+	if !final && z.c.Alphabet() == RuneAlphabet {
+		block = block[:len(block)-incompleteRuneSuffix(block)]
+	}
+	if len(block) == 0 {
+		return nil
+	}
+	data := bytes.Clone(block)
+	z.queue.add(int64(len(data)), func() ([]byte, error) {
+		return encodeBlock(data, z.c, z.maxCodeLength)
+	})
+	z.buf = z.buf[:copy(z.buf, z.buf[len(block):])]
+	// Keep one worker free for the block being buffered next.
+	return z.writeQueued(z.workers - 1)
+}
+
+// writeQueued writes coded blocks until no more than keep are left queued.
+func (z *Writer) writeQueued(keep int) error {
+	for z.queue.len() > keep {
+		result := z.queue.next()
+		if result.err != nil {
+			return result.err
+		}
+		_, err := z.w.Write(result.data)
+		if err != nil {
+			return err
+		}
+		z.index = append(z.index, indexEntry{rawLen: result.rawLen, length: int64(len(result.data))})
+	}
+	return nil
+}
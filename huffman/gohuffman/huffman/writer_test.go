@@ -0,0 +1,121 @@
+package huffman
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriterReaderRoundtrip(t *testing.T) {
+	text := strings.Repeat(streamTestText, 30)
+
+	var compressed bytes.Buffer
+	zw := NewWriter(&compressed)
+	for i := 0; i < len(text); i += 13 {
+		n, err := zw.Write([]byte(text[i:min(i+13, len(text))]))
+		assert.NoError(t, err)
+		assert.Equal(t, min(13, len(text)-i), n)
+	}
+	assert.NoError(t, zw.Close())
+	assert.NoError(t, zw.Close())
+	_, err := zw.Write([]byte("more"))
+	assert.Error(t, err)
+
+	zr, err := NewReader(&compressed)
+	assert.NoError(t, err)
+	assert.Equal(t, ByteAlphabet, zr.Container().Alphabet())
+	decoded, err := io.ReadAll(zr)
+	assert.NoError(t, err)
+	assert.Equal(t, text, string(decoded))
+	assert.NoError(t, zr.Close())
+}
+
+func TestWriterFlushAndRuneAlphabet(t *testing.T) {
+	var compressed bytes.Buffer
+	zw, err := NewWriterOptions(&compressed, WriterOptions{Alphabet: RuneAlphabet, BlockSize: 16})
+	assert.NoError(t, err)
+
+	euro := []byte("€")
+	_, err = zw.Write(append([]byte("price: 5"), euro[:1]...))
+	assert.NoError(t, err)
+	assert.NoError(t, zw.Flush())
+	_, err = zw.Write(append(euro[1:], " each"...))
+	assert.NoError(t, err)
+	assert.NoError(t, zw.Close())
+
+	zr, err := NewReader(&compressed)
+	assert.NoError(t, err)
+	decoded, err := io.ReadAll(zr)
+	assert.NoError(t, err)
+	assert.Equal(t, "price: 5€ each", string(decoded))
+}
+
+func TestNewWriterOptionsRejectsInvalidOptions(t *testing.T) {
+	_, err := NewWriterOptions(io.Discard, WriterOptions{Alphabet: 7})
+	assert.Error(t, err)
+	_, err = NewWriterOptions(io.Discard, WriterOptions{BlockSize: -1})
+	assert.Error(t, err)
+	_, err = NewWriterOptions(io.Discard, WriterOptions{BlockSize: DefaultMaxBlockSize + 1})
+	assert.Error(t, err)
+	_, err = NewWriterOptions(io.Discard, WriterOptions{Workers: -1})
+	assert.Error(t, err)
+}
+
+func TestReaderReadsEveryFormat(t *testing.T) {
+	var adaptive bytes.Buffer
+	assert.NoError(t, EncodeAdaptive(strings.NewReader(containerTestText), &adaptive, RuneAlphabet))
+
+	encodings := map[string][]byte{
+		"legacy":    encodeLegacy(t, containerTestText),
+		"freq":      encodeContainer(t, containerTestText, Container{HeaderType: HeaderFreq, Flags: FlagChecksum}),
+		"canonical": encodeContainer(t, containerTestText, Container{HeaderType: HeaderCanonical}),
+		"adaptive":  adaptive.Bytes(),
+	}
+	for name, encoded := range encodings {
+		t.Run(name, func(t *testing.T) {
+			zr, err := NewReader(bytes.NewReader(encoded))
+			assert.NoError(t, err)
+			decoded, err := io.ReadAll(zr)
+			assert.NoError(t, err)
+			assert.Equal(t, containerTestText, string(decoded))
+			assert.NoError(t, zr.Close())
+		})
+	}
+}
+
+func TestReaderReportsErrors(t *testing.T) {
+	_, err := NewReader(strings.NewReader("definitely not compressed"))
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+
+	var compressed bytes.Buffer
+	zw := NewWriter(&compressed)
+	_, err = zw.Write([]byte(containerTestText))
+	assert.NoError(t, err)
+	assert.NoError(t, zw.Close())
+	data := compressed.Bytes()
+	// Corrupt the coded data rather than the end marker and block index.
+	trailer := len(appendIndex([]byte{0}, zw.index))
+	data[len(data)-trailer-3] ^= 0x40
+
+	zr, err := NewReader(bytes.NewReader(data))
+	assert.NoError(t, err)
+	decoded, err := io.ReadAll(zr)
+	assert.Error(t, err)
+	assert.Empty(t, decoded)
+}
+
+func TestReaderCloseStopsDecoding(t *testing.T) {
+	encoded := encodeContainer(t, strings.Repeat(containerTestText, 100), Container{HeaderType: HeaderCanonical})
+	zr, err := NewReader(bytes.NewReader(encoded))
+	assert.NoError(t, err)
+
+	buf := make([]byte, 10)
+	_, err = io.ReadFull(zr, buf)
+	assert.NoError(t, err)
+	assert.NoError(t, zr.Close())
+	_, err = zr.Read(buf)
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+}
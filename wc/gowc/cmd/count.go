@@ -0,0 +1,90 @@
+package main
+
+import (
+	"io"
+	"unicode"
+	"unicode/utf8"
+)
+
+// bufferSize is how much of the input is held in memory at once.
+const bufferSize = 64 * 1024
+
+type counts struct {
+	lines int
+	words int
+	chars int
+	bytes int
+	// maxLineLength is the display width of the widest line and longestLine
+	// its number, counting from one. longestLine is zero if every line is
+	// empty.
+	maxLineLength int
+	longestLine   int
+}
+
+// countAll counts everything in a single pass over input, reading it through
+// a fixed-size buffer. Lines are counted by their newlines, like wc does, and
+// a byte that is not valid UTF-8 counts as one char. Line widths expand tabs
+// to the next tab stop, and carriage returns and form feeds start over at
+// the left margin.
+func countAll(input io.Reader) (counts, error) {
+	var c counts
+	buf := make([]byte, bufferSize)
+	inWord := false
+	linePos := 0
+	// pending is how many bytes at the start of buf belong to a rune that
+	// was cut off by the end of the previous read.
+	pending := 0
+	for {
+		n, err := input.Read(buf[pending:])
+		c.bytes += n
+		data := buf[:pending+n]
+		atEOF := err != nil
+		for len(data) > 0 {
+			r, size := rune(data[0]), 1
+			if r >= utf8.RuneSelf {
+				if !atEOF && !utf8.FullRune(data) {
+					break
+				}
+				r, size = utf8.DecodeRune(data)
+			}
+			data = data[size:]
+			c.chars++
+			switch r {
+			case '\n', '\r', '\f':
+				c.endLine(linePos)
+				linePos = 0
+			case '\t':
+				linePos += tabWidth - linePos%tabWidth
+			default:
+				linePos += runeWidth(r, size)
+			}
+			if r == '\n' {
+				c.lines++
+			}
+			if unicode.IsSpace(r) {
+				inWord = false
+			} else if !inWord {
+				inWord = true
+				c.words++
+			}
+		}
+		pending = copy(buf, data)
+		if err != nil {
+			// The last line counts even without a newline.
+			c.endLine(linePos)
+			if err == io.EOF {
+				return c, nil
+			}
+			return c, err
+		}
+	}
+}
+
+// endLine records the width of a line that has just ended. The line is the
+// one after the last newline counted.
+func (c *counts) endLine(width int) {
+	if width > c.maxLineLength {
+		c.maxLineLength = width
+		c.longestLine = c.lines + 1
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestCountAll(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  counts
+	}{
+		{"empty input", "", counts{}},
+		{"no trailing newline", "one two", counts{lines: 0, words: 2, chars: 7, bytes: 7, maxLineLength: 7, longestLine: 1}},
+		{"blank lines", "\n\n\n", counts{lines: 3, words: 0, chars: 3, bytes: 3}},
+		{"mixed whitespace", " a\tb \r\n  c\v", counts{lines: 1, words: 3, chars: 11, bytes: 11, maxLineLength: 10, longestLine: 1}},
+		{"multi-byte runes", "héllo wörld 世界\n", counts{lines: 1, words: 3, chars: 15, bytes: 21, maxLineLength: 16, longestLine: 1}},
+		{"unicode spaces separate words", "a　b c", counts{lines: 0, words: 3, chars: 5, bytes: 8, maxLineLength: 6, longestLine: 1}},
+		{"invalid utf-8 counts one char per byte", "a\xff\xfeb \xe4\xb8", counts{lines: 0, words: 2, chars: 7, bytes: 7, maxLineLength: 3, longestLine: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := countAll(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("error should be nil, got: %s", err)
+			}
+			if got != tt.want {
+				t.Errorf("counts should be %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+// splitReader returns the first n bytes of data in one read and the rest in
+// the next, to cut runes at every possible place.
+type splitReader struct {
+	data []byte
+	n    int
+}
+
+func (r *splitReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	take := len(r.data)
+	if r.n > 0 && r.n < take {
+		take = r.n
+		r.n = 0
+	}
+	n := copy(p, r.data[:take])
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestCountAllRunesSplitAcrossReads(t *testing.T) {
+	input := []byte("grüße, 世界! 🙂 done\n")
+	want, err := countAll(bytes.NewReader(input))
+	if err != nil {
+		t.Fatalf("error should be nil, got: %s", err)
+	}
+	for i := 1; i < len(input); i++ {
+		got, err := countAll(&splitReader{data: input, n: i})
+		if err != nil {
+			t.Fatalf("error should be nil, got: %s", err)
+		}
+		if got != want {
+			t.Errorf("split after %d bytes: counts should be %+v, got %+v", i, want, got)
+		}
+	}
+
+	got, err := countAll(iotest.OneByteReader(bytes.NewReader(input)))
+	if err != nil {
+		t.Fatalf("error should be nil, got: %s", err)
+	}
+	if got != want {
+		t.Errorf("one byte at a time: counts should be %+v, got %+v", want, got)
+	}
+}
+
+func TestCountAllSampleAcrossBuffers(t *testing.T) {
+	fileName := "../samples/gutenberg.org_cache_epub_132_pg132.txt"
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The sample is several buffers long, so runes get cut by buffer
+	// boundaries as well.
+	want := counts{lines: 7137, words: 58159, chars: 339120, bytes: 341836, maxLineLength: 74, longestLine: 7116}
+	for _, reader := range []io.Reader{bytes.NewReader(data), iotest.HalfReader(bytes.NewReader(data))} {
+		got, err := countAll(reader)
+		if err != nil {
+			t.Fatalf("error should be nil, got: %s", err)
+		}
+		if got != want {
+			t.Errorf("counts should be %+v, got %+v", want, got)
+		}
+	}
+}
+
+func TestCountAllMaxLineLength(t *testing.T) {
+	tests := []struct {
+		name            string
+		input           string
+		wantLength      int
+		wantLongestLine int
+	}{
+		{"the first of equally long lines", "abc\nxyz\n", 3, 1},
+		{"a later line", "a\nabcd\nab\n", 4, 2},
+		{"the last line without a newline", "ab\nabcde", 5, 2},
+		{"only empty lines", "\n\n", 0, 0},
+		{"tabs expand to the next tab stop", "a\tb\n\tc\n", 9, 1},
+		{"wide characters take two columns", "世界\nabc\n", 4, 1},
+		{"combining marks and control characters take none", "e\u0301\x01x\n", 2, 1},
+		{"carriage returns and form feeds start over", "abcdef\rxy\fz\n", 6, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := countAll(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("error should be nil, got: %s", err)
+			}
+			if got.maxLineLength != tt.wantLength || got.longestLine != tt.wantLongestLine {
+				t.Errorf("longest line should be %d columns on line %d, got %d on line %d",
+					tt.wantLength, tt.wantLongestLine, got.maxLineLength, got.longestLine)
+			}
+		})
+	}
+}
+
+func TestCountAllReportsReadErrors(t *testing.T) {
+	errRead := errors.New("read failed")
+	_, err := countAll(io.MultiReader(strings.NewReader("some text"), iotest.ErrReader(errRead)))
+	if !errors.Is(err, errRead) {
+		t.Errorf("error should be %s, got: %v", errRead, err)
+	}
+}
@@ -1,14 +1,16 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"os"
-	"strings"
+	"runtime"
+	"unicode"
+	"unicode/utf8"
 )
 
 type CliOptions struct {
@@ -16,11 +18,18 @@ type CliOptions struct {
 	shouldGetLineCount bool
 	shouldGetWordCount bool
 	shouldGetCharCount bool
-	fileNames          []string
+	// shouldGetMaxLineLength asks for the display width of the widest line,
+	// and shouldGetLongestLine for the number of that line.
+	shouldGetMaxLineLength bool
+	shouldGetLongestLine   bool
+	fileNames              []string
+	// jobs is how many files are counted at once. Zero means one.
+	jobs int
 }
 
 func (cliOptions *CliOptions) noOptionsSetDefault() {
-	if !cliOptions.shouldGetByteCount && !cliOptions.shouldGetLineCount && !cliOptions.shouldGetWordCount && !cliOptions.shouldGetCharCount {
+	if !cliOptions.shouldGetByteCount && !cliOptions.shouldGetLineCount && !cliOptions.shouldGetWordCount && !cliOptions.shouldGetCharCount &&
+		!cliOptions.shouldGetMaxLineLength && !cliOptions.shouldGetLongestLine {
 		cliOptions.shouldGetByteCount = true
 		cliOptions.shouldGetLineCount = true
 		cliOptions.shouldGetWordCount = true
@@ -31,159 +40,175 @@ func main() {
 	shouldGetLineCount := flag.Bool("l", false, "Number of lines in file or stdin")
 	shouldGetWordCount := flag.Bool("w", false, "Number of words in file or stdin")
 	shouldGetCharCount := flag.Bool("m", false, "Number of characters in file or stdin")
+	shouldGetMaxLineLength := flag.Bool("L", false, "Display width of the longest line in file or stdin")
+	shouldGetLongestLine := flag.Bool("longest-line", false, "Line number of the longest line, implies -L")
+	jobs := flag.Int("j", runtime.NumCPU(), "Number of files counted at once")
 	flag.Parse()
+	if *jobs < 1 {
+		log.Fatalf("invalid -j %d, must be at least 1", *jobs)
+	}
 
 	nonFlagArgCount := flag.NArg()
 	fileNameIndex := len(os.Args) - nonFlagArgCount
 	fileNames := os.Args[fileNameIndex:]
 
 	cliOptions := CliOptions{
-		shouldGetByteCount: *shouldGetByteCount,
-		shouldGetLineCount: *shouldGetLineCount,
-		shouldGetWordCount: *shouldGetWordCount,
-		shouldGetCharCount: *shouldGetCharCount,
-		fileNames:          fileNames,
+		shouldGetByteCount:     *shouldGetByteCount,
+		shouldGetLineCount:     *shouldGetLineCount,
+		shouldGetWordCount:     *shouldGetWordCount,
+		shouldGetCharCount:     *shouldGetCharCount,
+		shouldGetMaxLineLength: *shouldGetMaxLineLength || *shouldGetLongestLine,
+		shouldGetLongestLine:   *shouldGetLongestLine,
+		fileNames:              fileNames,
+		jobs:                   *jobs,
 	}
 
-	err := cliEntryPoint(&cliOptions, os.Stdout)
+	err := cliEntryPoint(&cliOptions, os.Stdout, os.Stderr)
+	if err == errInputFailed {
+		os.Exit(1)
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
 }
 
-func cliEntryPoint(cliOptions *CliOptions, output io.Writer) error {
+// errInputFailed means some input could not be counted. The errors have been
+// printed already, so main only needs to exit with a failure status.
+var errInputFailed = errors.New("some input could not be counted")
+
+func cliEntryPoint(cliOptions *CliOptions, output io.Writer, errOutput io.Writer) error {
 	cliOptions.noOptionsSetDefault()
 	if len(cliOptions.fileNames) == 0 {
 		//Stdin mode
-		cliForSingleFile(cliOptions, os.Stdin, output)
-		fmt.Fprintf(output, " \n")
-		return nil
+		return cliForSingleFile(cliOptions, os.Stdin, output, errOutput)
 	}
-	for i := range cliOptions.fileNames {
-		file, err := os.Open(cliOptions.fileNames[i])
-		if err != nil {
-			return nil
+
+	// Files that cannot be stat'ed are left out here and reported when
+	// they fail to open.
+	var stats []inputStat
+	for _, fileName := range cliOptions.fileNames {
+		info, err := os.Stat(fileName)
+		if err == nil {
+			stats = append(stats, inputStat{size: info.Size(), regular: info.Mode().IsRegular()})
 		}
+	}
+	width := cliOptions.columnWidth(len(cliOptions.fileNames), stats)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	results := countFiles(cliOptions.fileNames, cliOptions.jobs, stop)
 
-		err = cliForSingleFile(cliOptions, file, output)
-		fmt.Fprintf(output, " %s\n", cliOptions.fileNames[i])
+	var total counts
+	failed := false
+	for i, fileName := range cliOptions.fileNames {
+		result := <-results[i]
+		r, err := result.report, result.err
+		if err != nil {
+			printError(errOutput, fileName, err)
+			failed = true
+		}
+		if r == nil {
+			continue
+		}
+		total.add(r.counts)
+		err = writeReport(cliOptions, *r, width, output)
 		if err != nil {
 			return err
 		}
 	}
-	return nil
-}
-
-func cliForSingleFile(cliOptions *CliOptions, input io.Reader, output io.Writer) error {
-	var byteCount int
-	var lineCount int
-	var wordCount int
-	var charCount int
-	var err error
-	var data []byte
-	var reader io.Reader
-
-	//TODO: BUFFERING IN MEMORY. OPTIMIZE. DOING THIS BECUASE input gets reset after one option is executed
-	data, err = io.ReadAll(input)
-	reader = bytes.NewReader(data)
-	if cliOptions.shouldGetLineCount {
-		lineCount, err = getSplitCount(reader, bufio.ScanLines)
-		fmt.Fprintf(output, " %d", lineCount)
-		reader = nil
-	}
-	reader = bytes.NewReader(data)
-	if cliOptions.shouldGetWordCount {
-		wordCount, err = getSplitCount(reader, bufio.ScanWords)
-		fmt.Fprintf(output, " %d", wordCount)
-		reader = nil
-	}
-	reader = bytes.NewReader(data)
-	if cliOptions.shouldGetCharCount {
-		charCount, err = getSplitCount(reader, bufio.ScanRunes)
-		fmt.Fprintf(output, " %d", charCount)
-		reader = nil
-	}
-	reader = bytes.NewReader(data)
-	if cliOptions.shouldGetByteCount {
-		byteCount, err = getSplitCount(reader, bufio.ScanBytes)
-		fmt.Fprintf(output, " %d", byteCount)
-		reader = nil
+	if len(cliOptions.fileNames) > 1 {
+		err := writeReport(cliOptions, report{counts: total, name: "total"}, width, output)
+		if err != nil {
+			return err
+		}
 	}
-	if err != nil {
-		return err
+	if failed {
+		return errInputFailed
 	}
 	return nil
-
 }
-func filterEmptyStrings(words []string) []string {
-	finalWords := []string{}
-	for _, word := range words {
-		if word != "" {
-			finalWords = append(finalWords, word)
-		}
-	}
-	return finalWords
 
+// countResult is what countFile returns.
+type countResult struct {
+	report *report
+	err    error
 }
-func getWordCountInLine(line string) int {
-	count := 0
-	splitLine := strings.FieldsFunc(line, func(r rune) bool {
-		return r == ' ' || r == '\t'
-	})
-	count += len(filterEmptyStrings(splitLine))
-	return count
-}
-
-// TODO: Fix issues. This should be more performant than previous implementation. However it has issues with some special characters that take more bytes
-func cliForSingleFileNew(cliOptions *CliOptions, input io.Reader, output io.Writer) error {
-	scanner := bufio.NewScanner(input)
-	lineCount, wordCount, charCount, byteCount := 0, 0, 0, 0
-	for scanner.Scan() {
-		line := scanner.Text()
-		lineCount++
-
-		bytes := []byte(line)
-		byteCount += len(bytes)
-
-		// bytes := scanner.Bytes()
-		// byteCount += len(bytes)
 
-		// including \n in bytecount
-		// byteCount += 1
-
-		wordCount += getWordCountInLine(line)
-
-		charCount += len(line) + 1
+// countFiles counts fileNames with a pool of jobs workers, so no more than
+// jobs files are open at once. Every file gets its own channel for the
+// result, which lets the caller print them in order as soon as they are
+// ready. Closing stop makes the workers stop after the files they are on.
+func countFiles(fileNames []string, jobs int, stop <-chan struct{}) []chan countResult {
+	if jobs < 1 {
+		jobs = 1
+	}
+	results := make([]chan countResult, len(fileNames))
+	for i := range results {
+		results[i] = make(chan countResult, 1)
+	}
+	next := make(chan int)
+	go func() {
+		defer close(next)
+		for i := range fileNames {
+			select {
+			case next <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	for w := 0; w < jobs; w++ {
+		go func() {
+			for i := range next {
+				r, err := countFile(fileNames[i])
+				results[i] <- countResult{report: r, err: err}
+			}
+		}()
+	}
+	return results
+}
 
-		// including \n in charCount
-		// charCount += 1
+// countFile counts the named file. The report is nil if the file cannot be
+// opened; after a read error it holds what was counted up to it, like wc.
+func countFile(fileName string) (*report, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
 	}
+	defer file.Close()
+	c, err := countAll(file)
+	return &report{counts: c, name: fileName}, err
+}
 
-	//Adding charCount and byteCount for newline character
-	charCount += lineCount - 1
-	byteCount += lineCount - 1
-
-	if cliOptions.shouldGetLineCount {
-		fmt.Fprintf(output, " %d", lineCount)
-	}
-	if cliOptions.shouldGetWordCount {
-		fmt.Fprintf(output, " %d", wordCount)
+// cliForSingleFile counts standard input, or input standing in for it, and
+// prints the counts without a name. The counts are printed even if reading
+// fails, after reporting the error.
+func cliForSingleFile(cliOptions *CliOptions, input io.Reader, output io.Writer, errOutput io.Writer) error {
+	width := cliOptions.columnWidth(1, []inputStat{statInput(input)})
+	c, readErr := countAll(input)
+	if readErr != nil {
+		printError(errOutput, "'standard input'", readErr)
 	}
-	if cliOptions.shouldGetByteCount {
-		fmt.Fprintf(output, " %d", byteCount)
+	err := writeReport(cliOptions, report{counts: c}, width, output)
+	if err != nil {
+		return err
 	}
-	if cliOptions.shouldGetCharCount {
-		fmt.Fprintf(output, " %d", charCount)
+	if readErr != nil {
+		return errInputFailed
 	}
-
 	return nil
 }
-func getSplitCount(input io.Reader, splitFunc bufio.SplitFunc) (int, error) {
-	count := 0
-	scanner := bufio.NewScanner(input)
-	scanner.Split(splitFunc)
-	for scanner.Scan() {
-		count++
-	}
-	return count, nil
+
+// printError reports a failed input the way wc does, e.g.
+// "gowc: name: No such file or directory".
+func printError(errOutput io.Writer, name string, err error) {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+	message := err.Error()
+	if r, size := utf8.DecodeRuneInString(message); size > 0 {
+		message = string(unicode.ToUpper(r)) + message[size:]
+	}
+	fmt.Fprintf(errOutput, "gowc: %s: %s\n", name, message)
 }
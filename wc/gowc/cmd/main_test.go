@@ -3,10 +3,13 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestCliWcBytes(t *testing.T) {
@@ -22,15 +25,14 @@ func TestCliWcBytes(t *testing.T) {
 		}
 		var buffer bytes.Buffer
 		testWriter := bufio.NewWriter(&buffer)
-		error := cliEntryPoint(&cliOptions, testWriter)
+		error := cliEntryPoint(&cliOptions, testWriter, io.Discard)
 		if error != nil {
 			t.Errorf("error should be nil, got: %s", error)
 		}
 		testWriter.Flush()
 		got := buffer.String()
 		want := fmt.Sprintf("341836 %s\n", fileName)
-		//TODO: space seperation does not match exactly with the wc command
-		if strings.Trim(got, " ") != want {
+		if got != want {
 			t.Errorf("cli output should be %s, got %s.", want, got)
 		}
 	})
@@ -48,15 +50,14 @@ func TestCliWcBytes(t *testing.T) {
 		var buffer bytes.Buffer
 		testWriter := bufio.NewWriter(&buffer)
 		file, _ := os.Open(fileName)
-		error := cliForSingleFile(&cliOptions, file, testWriter)
+		error := cliForSingleFile(&cliOptions, file, testWriter, io.Discard)
 		if error != nil {
 			t.Errorf("error should be nil, got: %s", error)
 		}
 		testWriter.Flush()
 		got := buffer.String()
-		want := "341836"
-		//TODO: space seperation does not match exactly with the wc command
-		if strings.Trim(got, " ") != strings.Trim(want, " ") {
+		want := "341836\n"
+		if got != want {
 			t.Errorf("cli output should be %s, got %s.", want, got)
 		}
 	})
@@ -75,15 +76,14 @@ func TestCliWcLine(t *testing.T) {
 		}
 		var buffer bytes.Buffer
 		testWriter := bufio.NewWriter(&buffer)
-		error := cliEntryPoint(&cliOptions, testWriter)
+		error := cliEntryPoint(&cliOptions, testWriter, io.Discard)
 		if error != nil {
 			t.Errorf("error should be nil, got: %s", error)
 		}
 		testWriter.Flush()
 		got := buffer.String()
 		want := fmt.Sprintf("7137 %s\n", fileName)
-		//TODO: space seperation does not match exactly with the wc command
-		if strings.Trim(got, " ") != want {
+		if got != want {
 			t.Errorf("cli output should be %s, got %s.", want, got)
 		}
 	})
@@ -102,15 +102,14 @@ func TestCliWcWord(t *testing.T) {
 		}
 		var buffer bytes.Buffer
 		testWriter := bufio.NewWriter(&buffer)
-		error := cliEntryPoint(&cliOptions, testWriter)
+		error := cliEntryPoint(&cliOptions, testWriter, io.Discard)
 		if error != nil {
 			t.Errorf("error should be nil, got: %s", error)
 		}
 		testWriter.Flush()
 		got := buffer.String()
 		want := fmt.Sprintf("58159 %s\n", fileName)
-		//TODO: space seperation does not match exactly with the wc command
-		if strings.Trim(got, " ") != want {
+		if got != want {
 			t.Errorf("cli output should be %s, got %s.", want, got)
 		}
 	})
@@ -129,15 +128,14 @@ func TestCliWcChar(t *testing.T) {
 		}
 		var buffer bytes.Buffer
 		testWriter := bufio.NewWriter(&buffer)
-		error := cliEntryPoint(&cliOptions, testWriter)
+		error := cliEntryPoint(&cliOptions, testWriter, io.Discard)
 		if error != nil {
 			t.Errorf("error should be nil, got: %s", error)
 		}
 		testWriter.Flush()
 		got := buffer.String()
 		want := fmt.Sprintf("339120 %s\n", fileName)
-		//TODO: space seperation does not match exactly with the wc command
-		if strings.Trim(got, " ") != want {
+		if got != want {
 			t.Errorf("cli output should be %s, got %s.", want, got)
 		}
 	})
@@ -156,20 +154,190 @@ func TestCliDefault(t *testing.T) {
 		}
 		var buffer bytes.Buffer
 		testWriter := bufio.NewWriter(&buffer)
-		error := cliEntryPoint(&cliOptions, testWriter)
+		error := cliEntryPoint(&cliOptions, testWriter, io.Discard)
 		if error != nil {
 			t.Errorf("error should be nil, got: %s", error)
 		}
 		testWriter.Flush()
 		got := buffer.String()
-		want := fmt.Sprintf("  7137 58159 341836 %s\n", fileName)
-		//TODO: space seperation does not match exactly with the wc command
-		if strings.Trim(got, " ") != strings.Trim(want, " ") {
+		want := fmt.Sprintf("  7137  58159 341836 %s\n", fileName)
+		if got != want {
 			t.Errorf("cli output should be %s, got %s.", want, got)
 		}
 	})
 
 }
+func TestCliFormatting(t *testing.T) {
+	bigFile := "../samples/gutenberg.org_cache_epub_132_pg132.txt"
+	smallFile := "../samples/sample.txt"
+	tests := []struct {
+		name       string
+		cliOptions CliOptions
+		want       string
+	}{
+		{
+			name:       "columns of a single file are as wide as its byte count",
+			cliOptions: CliOptions{fileNames: []string{smallFile}},
+			want:       fmt.Sprintf("  2  27 137 %s\n", smallFile),
+		},
+		{
+			name:       "byte count sizes the columns even when not printed",
+			cliOptions: CliOptions{shouldGetLineCount: true, shouldGetWordCount: true, fileNames: []string{smallFile}},
+			want:       fmt.Sprintf("  2  27 %s\n", smallFile),
+		},
+		{
+			name:       "counters are printed as lines, words, chars, bytes",
+			cliOptions: CliOptions{shouldGetByteCount: true, shouldGetCharCount: true, shouldGetLineCount: true, fileNames: []string{smallFile}},
+			want:       fmt.Sprintf("  2 137 137 %s\n", smallFile),
+		},
+		{
+			name:       "multiple files get a total line",
+			cliOptions: CliOptions{fileNames: []string{bigFile, smallFile}},
+			want: fmt.Sprintf("  7137  58159 341836 %s\n     2     27    137 %s\n  7139  58186 341973 total\n",
+				bigFile, smallFile),
+		},
+		{
+			name:       "a single counter is aligned with the total",
+			cliOptions: CliOptions{shouldGetLineCount: true, fileNames: []string{smallFile, smallFile}},
+			want:       fmt.Sprintf("  2 %s\n  2 %s\n  4 total\n", smallFile, smallFile),
+		},
+		{
+			name:       "max line length comes after bytes",
+			cliOptions: CliOptions{shouldGetByteCount: true, shouldGetMaxLineLength: true, fileNames: []string{smallFile}},
+			want:       fmt.Sprintf("137 104 %s\n", smallFile),
+		},
+		{
+			name: "the total longest line is numbered across all files",
+			cliOptions: CliOptions{shouldGetMaxLineLength: true, shouldGetLongestLine: true,
+				fileNames: []string{bigFile, smallFile}},
+			want: fmt.Sprintf("    74   7116 %s\n   104      2 %s\n   104   7139 total\n", bigFile, smallFile),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			err := cliEntryPoint(&tt.cliOptions, &buffer, io.Discard)
+			if err != nil {
+				t.Errorf("error should be nil, got: %s", err)
+			}
+			if got := buffer.String(); got != tt.want {
+				t.Errorf("cli output should be\n%s got\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCliFormattingOfPipes(t *testing.T) {
+	t.Run("input that is not a regular file gets columns of at least 7", func(t *testing.T) {
+		cliOptions := CliOptions{}
+		cliOptions.noOptionsSetDefault()
+		var buffer bytes.Buffer
+		err := cliForSingleFile(&cliOptions, strings.NewReader("hello world\n"), &buffer, io.Discard)
+		if err != nil {
+			t.Errorf("error should be nil, got: %s", err)
+		}
+		want := "      1       2      12\n"
+		if got := buffer.String(); got != want {
+			t.Errorf("cli output should be %q, got %q", want, got)
+		}
+	})
+}
+
+func TestCliErrors(t *testing.T) {
+	smallFile := "../samples/sample.txt"
+	missingFile := "../samples/no-such-file.txt"
+	tests := []struct {
+		name       string
+		fileNames  []string
+		wantOutput string
+		wantErrors string
+	}{
+		{
+			name:       "a missing file is reported and the others are still counted",
+			fileNames:  []string{missingFile, smallFile, smallFile},
+			wantOutput: fmt.Sprintf("  2 %s\n  2 %s\n  4 total\n", smallFile, smallFile),
+			wantErrors: fmt.Sprintf("gowc: %s: No such file or directory\n", missingFile),
+		},
+		{
+			name:       "a single missing file prints no counts",
+			fileNames:  []string{missingFile},
+			wantOutput: "",
+			wantErrors: fmt.Sprintf("gowc: %s: No such file or directory\n", missingFile),
+		},
+		{
+			name:       "a directory is reported with the counts read before the error",
+			fileNames:  []string{"../samples", smallFile},
+			wantOutput: fmt.Sprintf("      0 ../samples\n      2 %s\n      2 total\n", smallFile),
+			wantErrors: "gowc: ../samples: Is a directory\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cliOptions := CliOptions{shouldGetLineCount: true, fileNames: tt.fileNames}
+			var output, errOutput bytes.Buffer
+			err := cliEntryPoint(&cliOptions, &output, &errOutput)
+			if err != errInputFailed {
+				t.Errorf("error should be %s, got: %v", errInputFailed, err)
+			}
+			if got := output.String(); got != tt.wantOutput {
+				t.Errorf("cli output should be\n%s got\n%s", tt.wantOutput, got)
+			}
+			if got := errOutput.String(); got != tt.wantErrors {
+				t.Errorf("error output should be %q, got %q", tt.wantErrors, got)
+			}
+		})
+	}
+
+	t.Run("a read error on stdin is reported after counting what was read", func(t *testing.T) {
+		cliOptions := CliOptions{shouldGetByteCount: true}
+		var output, errOutput bytes.Buffer
+		input := io.MultiReader(strings.NewReader("hello"), iotest.ErrReader(errors.New("read failed")))
+		err := cliForSingleFile(&cliOptions, input, &output, &errOutput)
+		if err != errInputFailed {
+			t.Errorf("error should be %s, got: %v", errInputFailed, err)
+		}
+		if got, want := output.String(), "5\n"; got != want {
+			t.Errorf("cli output should be %q, got %q", want, got)
+		}
+		if got, want := errOutput.String(), "gowc: 'standard input': Read failed\n"; got != want {
+			t.Errorf("error output should be %q, got %q", want, got)
+		}
+	})
+}
+
+func TestCliJobs(t *testing.T) {
+	bigFile := "../samples/gutenberg.org_cache_epub_132_pg132.txt"
+	smallFile := "../samples/sample.txt"
+	missingFile := "../samples/no-such-file.txt"
+	var fileNames []string
+	for i := 0; i < 20; i++ {
+		fileNames = append(fileNames, bigFile, missingFile, smallFile)
+	}
+
+	cliOptions := CliOptions{fileNames: fileNames, jobs: 1}
+	var want, wantErrors bytes.Buffer
+	err := cliEntryPoint(&cliOptions, &want, &wantErrors)
+	if err != errInputFailed {
+		t.Fatalf("error should be %s, got: %v", errInputFailed, err)
+	}
+	for _, jobs := range []int{2, 8, 100} {
+		t.Run(fmt.Sprintf("%d jobs", jobs), func(t *testing.T) {
+			cliOptions := CliOptions{fileNames: fileNames, jobs: jobs}
+			var got, gotErrors bytes.Buffer
+			err := cliEntryPoint(&cliOptions, &got, &gotErrors)
+			if err != errInputFailed {
+				t.Errorf("error should be %s, got: %v", errInputFailed, err)
+			}
+			if got.String() != want.String() {
+				t.Errorf("cli output should be\n%s got\n%s", want.String(), got.String())
+			}
+			if gotErrors.String() != wantErrors.String() {
+				t.Errorf("error output should be\n%s got\n%s", wantErrors.String(), gotErrors.String())
+			}
+		})
+	}
+}
+
 func BenchmarkCli(b *testing.B) {
 	b.ResetTimer()
 	count := 1000
@@ -185,8 +353,23 @@ func BenchmarkCli(b *testing.B) {
 		}
 		var buffer bytes.Buffer
 		testWriter := bufio.NewWriter(&buffer)
-		cliEntryPoint(&cliOptions, testWriter)
+		cliEntryPoint(&cliOptions, testWriter, io.Discard)
 		testWriter.Flush()
 
 	}
 }
+
+func BenchmarkCliJobs(b *testing.B) {
+	fileNames := make([]string, 100)
+	for i := range fileNames {
+		fileNames[i] = "../samples/gutenberg.org_cache_epub_132_pg132.txt"
+	}
+	for _, jobs := range []int{1, 4} {
+		b.Run(fmt.Sprintf("%d jobs", jobs), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				cliOptions := CliOptions{fileNames: fileNames, jobs: jobs}
+				cliEntryPoint(&cliOptions, io.Discard, io.Discard)
+			}
+		})
+	}
+}
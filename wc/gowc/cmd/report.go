@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// report is one line of output: the counts of an input, or the total.
+type report struct {
+	counts counts
+	name   string
+}
+
+// inputStat is what sizing the columns needs to know about an input.
+type inputStat struct {
+	size int64
+	// regular is false for input that is not a regular file, like a pipe,
+	// whose size is not known up front.
+	regular bool
+}
+
+// statInput returns the stat of input if it is a file. Other readers are
+// treated like pipes.
+func statInput(input io.Reader) inputStat {
+	file, ok := input.(*os.File)
+	if !ok {
+		return inputStat{}
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return inputStat{}
+	}
+	return inputStat{size: info.Size(), regular: info.Mode().IsRegular()}
+}
+
+// values returns the selected counts in the order wc prints them in.
+func (cliOptions *CliOptions) values(c counts) []int {
+	var values []int
+	if cliOptions.shouldGetLineCount {
+		values = append(values, c.lines)
+	}
+	if cliOptions.shouldGetWordCount {
+		values = append(values, c.words)
+	}
+	if cliOptions.shouldGetCharCount {
+		values = append(values, c.chars)
+	}
+	if cliOptions.shouldGetByteCount {
+		values = append(values, c.bytes)
+	}
+	if cliOptions.shouldGetMaxLineLength {
+		values = append(values, c.maxLineLength)
+	}
+	if cliOptions.shouldGetLongestLine {
+		values = append(values, c.longestLine)
+	}
+	return values
+}
+
+// columnWidth sizes the columns like GNU wc, before anything is counted: to
+// the total size of the regular files, which no count exceeds, and to at
+// least 7 if any input is not a regular file. A single number for a single
+// input is not padded at all.
+func (cliOptions *CliOptions) columnWidth(inputCount int, stats []inputStat) int {
+	if inputCount == 1 && len(cliOptions.values(counts{})) == 1 {
+		return 1
+	}
+	var total int64
+	width := 1
+	for _, stat := range stats {
+		if stat.regular {
+			total += stat.size
+		} else {
+			width = 7
+		}
+	}
+	if digits := len(strconv.FormatInt(total, 10)); digits > width {
+		width = digits
+	}
+	return width
+}
+
+// writeReport prints the selected counts right-aligned to width, followed by
+// the name if there is one.
+func writeReport(cliOptions *CliOptions, r report, width int, output io.Writer) error {
+	var fields []string
+	for _, value := range cliOptions.values(r.counts) {
+		fields = append(fields, fmt.Sprintf("%*d", width, value))
+	}
+	if r.name != "" {
+		fields = append(fields, r.name)
+	}
+	_, err := fmt.Fprintln(output, strings.Join(fields, " "))
+	return err
+}
+
+// add adds other to c as if its input followed the input of c, so the line
+// number of the longest line is counted across both.
+func (c *counts) add(other counts) {
+	if other.maxLineLength > c.maxLineLength {
+		c.maxLineLength = other.maxLineLength
+		c.longestLine = c.lines + other.longestLine
+	}
+	c.lines += other.lines
+	c.words += other.words
+	c.chars += other.chars
+	c.bytes += other.bytes
+}